@@ -0,0 +1,114 @@
+package ffmpeg
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	repeatCountPtrn = regexp.MustCompile(`Last message repeated (\d+) times`)
+	statsTimePtrn   = regexp.MustCompile(`time=\s*([^\s]+)`)
+)
+
+var anomalyPatterns = []struct {
+	typ  AnomalyType
+	ptrn *regexp.Regexp
+}{
+	{NonMonotonicDTS, regexp.MustCompile(`(?i)non-monotonous dts in output stream (\d+:\d+)`)},
+	{NonMonotonicDTS, regexp.MustCompile(`(?i)non monotonically increasing dts to muxer in stream (\d+)`)},
+	{PastDurationTooLarge, regexp.MustCompile(`(?i)past duration [0-9.]+ too large`)},
+	{CorruptFrame, regexp.MustCompile(`(?i)corrupt decoded frame in stream (\d+)`)},
+	{MissingReference, regexp.MustCompile(`(?i)missing reference picture|reference picture missing|mmco: unref short failure`)},
+	{TimestampDiscontinuity, regexp.MustCompile(`(?i)timestamp discontinuity for stream #(\d+:\d+)`)},
+	{TimestampDiscontinuity, regexp.MustCompile(`(?i)(?:timestamp|dts) discontinuity(?: in stream (\d+))?`)},
+}
+
+// Anomaly is a warning from the ffmpeg log that suggests the output may be
+// damaged, even if ffmpeg exited successfully
+type Anomaly struct {
+	// Type of the anomaly
+	Type AnomalyType
+
+	// Time is the approximate output position when the anomaly first occurred
+	Time Time
+
+	// Stream is the stream the anomaly occurred in, as reported by ffmpeg.  This is
+	// either an input stream index ("1") or an output stream ("0:1").  Stream is empty
+	// if ffmpeg did not say
+	Stream string
+
+	// Count is the number of times the anomaly occurred.  Consecutive anomalies of the
+	// same type and stream are counted together, as are repeats of the same message
+	Count int
+
+	// Message is the first log line that reported the anomaly
+	Message string
+}
+
+// anomalyDetector collects anomalies from successive log lines
+type anomalyDetector struct {
+	anomalies []Anomaly
+
+	// repeatable is set when the previous log line was an anomaly so that a
+	// "Last message repeated" line adds to its count
+	repeatable bool
+}
+
+// line checks a log line for a known anomaly that occurred at the given output position
+func (ad *anomalyDetector) line(text string, t Time) {
+	ad.repeatable = false
+	for _, ap := range anomalyPatterns {
+		match := ap.ptrn.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+
+		stream := ""
+		if len(match) > 1 {
+			stream = match[1]
+		}
+
+		ad.repeatable = true
+		if last := len(ad.anomalies) - 1; last >= 0 && ad.anomalies[last].Type == ap.typ && ad.anomalies[last].Stream == stream {
+			ad.anomalies[last].Count++
+		} else {
+			ad.anomalies = append(ad.anomalies, Anomaly{Type: ap.typ, Time: t, Stream: stream, Count: 1, Message: text})
+		}
+		return
+	}
+}
+
+// repeat handles a "Last message repeated N times" line
+func (ad *anomalyDetector) repeat(text string) {
+	match := repeatCountPtrn.FindStringSubmatch(text)
+	if match == nil || !ad.repeatable {
+		return
+	}
+
+	if count, err := strconv.Atoi(match[1]); err == nil {
+		ad.anomalies[len(ad.anomalies)-1].Count += count
+	}
+}
+
+// ParseAnomalies finds the anomalies in an ffmpeg log, such as the one returned by
+// TranscodeJob.Log or Check.  The times of the anomalies are only known if the log
+// includes the periodic statistics lines ("frame= ... time=00:01:02.03 ..."),
+// otherwise they are zero.  Running jobs collect anomalies in TranscodeResult
+func ParseAnomalies(log string) []Anomaly {
+	detector := &anomalyDetector{}
+	var t Time
+	for _, line := range strings.Split(log, "\n") {
+		line = strings.TrimSpace(line)
+		if repeatPtrn.MatchString(line) {
+			detector.repeat(line)
+		} else if statsPtrn.MatchString(line) {
+			if match := statsTimePtrn.FindStringSubmatch(line); match != nil {
+				t.Parse(match[1])
+			}
+		} else {
+			detector.line(line, t)
+		}
+	}
+	return detector.anomalies
+}
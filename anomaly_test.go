@@ -0,0 +1,78 @@
+package ffmpeg
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/mh-orange/cmd"
+)
+
+func TestParseAnomalies(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Anomaly
+	}{
+		{"clean", "Input #0, mpegts, from 'foo.ts':\n  Duration: 00:00:10.01", nil},
+		{"corrupt frame", "[h264 @ 0x5600] error while decoding MB 12 34\ncorrupt decoded frame in stream 0", []Anomaly{
+			{Type: CorruptFrame, Stream: "0", Count: 1, Message: "corrupt decoded frame in stream 0"},
+		}},
+		{"repeated", "[h264 @ 0x5600] Missing reference picture, default is 65530\n[h264 @ 0x5600] Last message repeated 3 times", []Anomaly{
+			{Type: MissingReference, Count: 4, Message: "[h264 @ 0x5600] Missing reference picture, default is 65530"},
+		}},
+		{"repeat of other message", "Input #0, mpegts, from 'foo.ts':\nLast message repeated 3 times", nil},
+		{"discontinuity", "[mpegts @ 0x5600] DTS discontinuity in stream 1: packet 5 with DTS 1000, packet 6 with DTS 9000\ntimestamp discontinuity for stream #0:1 (id=257, type=audio): -26637, new offset= 26651", []Anomaly{
+			{Type: TimestampDiscontinuity, Stream: "1", Count: 1, Message: "[mpegts @ 0x5600] DTS discontinuity in stream 1: packet 5 with DTS 1000, packet 6 with DTS 9000"},
+			{Type: TimestampDiscontinuity, Stream: "0:1", Count: 1, Message: "timestamp discontinuity for stream #0:1 (id=257, type=audio): -26637, new offset= 26651"},
+		}},
+		{"stats time", "frame=  120 fps= 60 q=28.0 size=     916kB time=00:00:05.00 bitrate=1500.2kbits/s speed=2.5x\n[mp4 @ 0x5600] Application provided invalid, non monotonically increasing dts to muxer in stream 1: 100 >= 90", []Anomaly{
+			{Type: NonMonotonicDTS, Time: 5 * Second, Stream: "1", Count: 1, Message: "[mp4 @ 0x5600] Application provided invalid, non monotonically increasing dts to muxer in stream 1: 100 >= 90"},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ParseAnomalies(test.input)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want %+v got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestTranscodeJobAnomalies(t *testing.T) {
+	oldFfmpeg := Ffmpeg
+	input, err := ioutil.ReadFile("testdata/transcode2.txt")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	Ffmpeg = &cmd.TestCmd{Stderr: input}
+
+	job, err := NewTranscoder().Transcode()
+	if err == nil {
+		err = job.Wait()
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []AnomalyType{MissingReference, NonMonotonicDTS, PastDurationTooLarge}
+	wantCounts := []int{3, 2, 1}
+	got := job.Result().Anomalies
+	if len(got) != len(want) {
+		t.Fatalf("want %d anomalies got %+v", len(want), got)
+	}
+
+	for i, anomaly := range got {
+		if anomaly.Type != want[i] || anomaly.Count != wantCounts[i] {
+			t.Errorf("anomalies[%d] want %v x%d got %v x%d", i, want[i], wantCounts[i], anomaly.Type, anomaly.Count)
+		}
+
+		if anomaly.Time != 5005*Millisecond {
+			t.Errorf("anomalies[%d] want time %v got %v", i, 5005*Millisecond, anomaly.Time)
+		}
+	}
+	Ffmpeg = oldFfmpeg
+}
@@ -0,0 +1,529 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mh-orange/cmd"
+)
+
+// ErrUnsupported is the cause of an UnsupportedError
+var ErrUnsupported = errors.New("not supported by ffmpeg")
+
+var (
+	versionPtrn = regexp.MustCompile(`^ffmpeg version (\S+)`)
+	libraryPtrn = regexp.MustCompile(`^(lib\w+)\s+([0-9. ]+?)\s*/`)
+	filterPtrn  = regexp.MustCompile(`^([TSC.]{2,3})\s+(\S+)\s+(\S+)->(\S+)\s+(.*)$`)
+)
+
+// UnsupportedError is returned when a transcode job requires something (an encoder,
+// filter, format etc) that the ffmpeg build does not support
+type UnsupportedError struct {
+	// Kind of the missing capability: encoder, decoder, filter, muxer, demuxer,
+	// protocol or pixel format
+	Kind string
+
+	// Name of the missing capability, for instance libx264
+	Name string
+}
+
+func (ue *UnsupportedError) Error() string {
+	return fmt.Sprintf("ffmpeg does not support %s %s", ue.Kind, ue.Name)
+}
+
+// Unwrap returns ErrUnsupported so that errors.Is can be used to check for
+// unsupported capabilities
+func (ue *UnsupportedError) Unwrap() error {
+	return ErrUnsupported
+}
+
+// Codec describes an encoder or decoder
+type Codec struct {
+	// Name of the codec, as given to options such as -c:v
+	Name string
+
+	// Description is the long name of the codec
+	Description string
+
+	// Type is the kind of media the codec handles
+	Type MediaType
+
+	// Flags are the capability flags printed by ffmpeg (such as "V....D")
+	Flags string
+}
+
+// Filter describes a libavfilter filter
+type Filter struct {
+	// Name of the filter, as used in a filter chain
+	Name string
+
+	// Description of what the filter does
+	Description string
+
+	// Inputs and Outputs are the pad types (such as "V", "A" or "N" for dynamic)
+	Inputs  string
+	Outputs string
+
+	// Flags are the capability flags printed by ffmpeg (such as "TSC")
+	Flags string
+}
+
+// ContainerFormat describes a muxer and/or demuxer
+type ContainerFormat struct {
+	// Name of the format, as given to -f.  Some demuxers handle several formats and
+	// have a comma separated list of names (such as "matroska,webm")
+	Name string
+
+	// Description is the long name of the format
+	Description string
+
+	// Demux is true if the format can be read
+	Demux bool
+
+	// Mux is true if the format can be written
+	Mux bool
+}
+
+// PixelFormat describes a pixel format
+type PixelFormat struct {
+	// Name of the pixel format, as given to -pix_fmt
+	Name string
+
+	// Components is the number of color components
+	Components int
+
+	// BitsPerPixel is the number of bits used to store a pixel
+	BitsPerPixel int
+
+	// Input is true if the format is supported as input for conversion
+	Input bool
+
+	// Output is true if the format is supported as output for conversion
+	Output bool
+}
+
+// Capabilities is what an ffmpeg build supports
+type Capabilities struct {
+	// Version is the ffmpeg version string
+	Version string
+
+	// Configuration are the options ffmpeg was configured with
+	Configuration []string
+
+	// Libraries are the versions of the libraries ffmpeg was built with, for
+	// instance "libavcodec": "58.134.100"
+	Libraries map[string]string
+
+	Encoders        []Codec
+	Decoders        []Codec
+	Filters         []Filter
+	Formats         []ContainerFormat
+	InputProtocols  []string
+	OutputProtocols []string
+	PixelFormats    []PixelFormat
+}
+
+// cacheKeyer is implemented by commands whose Path alone does not identify the ffmpeg
+// build that runs, such as those of the ContainerExecutor
+type cacheKeyer interface {
+	cacheKey() string
+}
+
+// capabilitiesEntry is a cached (or loading) set of capabilities.  done is closed
+// once loading has finished
+type capabilitiesEntry struct {
+	done         chan struct{}
+	capabilities *Capabilities
+	err          error
+}
+
+// capabilitiesCache holds an entry per ffmpeg build.  The lock only guards the map,
+// it is not held while ffmpeg runs so a slow build does not hold up the others
+var capabilitiesCache = struct {
+	sync.Mutex
+	entries map[string]*capabilitiesEntry
+}{entries: make(map[string]*capabilitiesEntry)}
+
+// LoadCapabilities runs ffmpeg with -version, -encoders, -decoders, -filters, -formats,
+// -protocols and -pix_fmts and returns what it supports.  The capabilities are cached
+// so ffmpeg is only run the first time
+func LoadCapabilities(ctx context.Context) (*Capabilities, error) {
+	return loadCapabilities(ctx, Ffmpeg)
+}
+
+func loadCapabilities(ctx context.Context, command cmd.Command) (*Capabilities, error) {
+	key := command.Path()
+	if keyer, ok := command.(cacheKeyer); ok {
+		key = keyer.cacheKey()
+	}
+
+	for {
+		capabilitiesCache.Lock()
+		entry, found := capabilitiesCache.entries[key]
+		if !found {
+			entry = &capabilitiesEntry{done: make(chan struct{})}
+			capabilitiesCache.entries[key] = entry
+		}
+		capabilitiesCache.Unlock()
+
+		if !found {
+			entry.capabilities, entry.err = probeCapabilities(ctx, command)
+			if entry.err != nil {
+				// failures are not cached, the next caller runs ffmpeg again
+				capabilitiesCache.Lock()
+				delete(capabilitiesCache.entries, key)
+				capabilitiesCache.Unlock()
+			}
+			close(entry.done)
+			return entry.capabilities, entry.err
+		}
+
+		select {
+		case <-entry.done:
+			if entry.err == nil {
+				return entry.capabilities, nil
+			}
+			// the caller that was loading failed (possibly because its context was
+			// done), so try again with this context
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// probeCapabilities runs ffmpeg with each of the flags and parses the output
+func probeCapabilities(ctx context.Context, command cmd.Command) (*Capabilities, error) {
+	c := &Capabilities{Libraries: make(map[string]string)}
+	parsers := []struct {
+		flag  string
+		parse func([]byte)
+	}{
+		{"-version", c.parseVersion},
+		{"-encoders", func(output []byte) { c.Encoders = parseCodecs(output) }},
+		{"-decoders", func(output []byte) { c.Decoders = parseCodecs(output) }},
+		{"-filters", c.parseFilters},
+		{"-formats", c.parseFormats},
+		{"-protocols", c.parseProtocols},
+		{"-pix_fmts", c.parsePixelFormats},
+	}
+
+	for _, parser := range parsers {
+		output, err := runOutput(ctx, command, parser.flag)
+		if err != nil {
+			return nil, err
+		}
+		parser.parse(output)
+	}
+	return c, nil
+}
+
+// runOutput starts the command with the arguments and returns its standard output
+func runOutput(ctx context.Context, command cmd.Command, args ...string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	proc := command.Process()
+	proc.AppendArgs(args...)
+	proc.Stdout(buf)
+	err := notFound(proc.Start(), ErrFfmpegNotFound)
+	if err == nil {
+		done := make(chan struct{})
+		watchContext(ctx, proc, done)
+		err = proc.Wait()
+		close(done)
+	}
+
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return buf.Bytes(), err
+}
+
+// nonEmptyLines returns the trimmed, non-empty lines of the output
+func nonEmptyLines(output []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// afterSeparator returns the lines following the first line made up of dashes
+func afterSeparator(output []byte) []string {
+	lines := nonEmptyLines(output)
+	for i, line := range lines {
+		if strings.Trim(line, "-") == "" {
+			return lines[i+1:]
+		}
+	}
+	return nil
+}
+
+func (c *Capabilities) parseVersion(output []byte) {
+	for _, line := range nonEmptyLines(output) {
+		if match := versionPtrn.FindStringSubmatch(line); match != nil {
+			c.Version = match[1]
+		} else if strings.HasPrefix(line, "configuration:") {
+			c.Configuration = strings.Fields(strings.TrimPrefix(line, "configuration:"))
+		} else if match := libraryPtrn.FindStringSubmatch(line); match != nil {
+			c.Libraries[match[1]] = strings.Replace(match[2], " ", "", -1)
+		}
+	}
+}
+
+func parseCodecs(output []byte) (codecs []Codec) {
+	types := map[byte]MediaType{'V': Video, 'A': Audio, 'S': Subtitle, 'D': Data, 'T': Attachment}
+	for _, line := range afterSeparator(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		codec := Codec{Name: fields[1], Flags: fields[0], Description: strings.Join(fields[2:], " ")}
+		codec.Type = types[fields[0][0]]
+		codecs = append(codecs, codec)
+	}
+	return codecs
+}
+
+func (c *Capabilities) parseFilters(output []byte) {
+	for _, line := range nonEmptyLines(output) {
+		if match := filterPtrn.FindStringSubmatch(line); match != nil {
+			c.Filters = append(c.Filters, Filter{Name: match[2], Description: match[5], Inputs: match[3], Outputs: match[4], Flags: match[1]})
+		}
+	}
+}
+
+// parseFormats reads the format list, the first columns of every line are the
+// demux and mux flags (and, in newer versions, the device flag)
+func (c *Capabilities) parseFormats(output []byte) {
+	width := 0
+	started := false
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if !started {
+			if width == 0 && len(fields) > 0 && strings.HasPrefix(fields[0], "D.") {
+				width = len(fields[0])
+			}
+			started = width > 0 && len(fields) == 1 && strings.Trim(fields[0], "-") == ""
+			continue
+		}
+
+		if len(line) <= width+1 || len(fields) < 2 {
+			continue
+		}
+
+		flags := line[1 : width+1]
+		fields = strings.Fields(line[width+1:])
+		c.Formats = append(c.Formats, ContainerFormat{
+			Name:        fields[0],
+			Description: strings.Join(fields[1:], " "),
+			Demux:       flags[0] == 'D',
+			Mux:         flags[1] == 'E',
+		})
+	}
+}
+
+func (c *Capabilities) parseProtocols(output []byte) {
+	var protocols *[]string
+	for _, line := range nonEmptyLines(output) {
+		switch line {
+		case "Input:":
+			protocols = &c.InputProtocols
+		case "Output:":
+			protocols = &c.OutputProtocols
+		default:
+			if protocols != nil {
+				*protocols = append(*protocols, line)
+			}
+		}
+	}
+}
+
+func (c *Capabilities) parsePixelFormats(output []byte) {
+	for _, line := range afterSeparator(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		pf := PixelFormat{Name: fields[1], Input: fields[0][0] == 'I', Output: fields[0][1] == 'O'}
+		pf.Components, _ = strconv.Atoi(fields[2])
+		pf.BitsPerPixel, _ = strconv.Atoi(fields[3])
+		c.PixelFormats = append(c.PixelFormats, pf)
+	}
+}
+
+// HasEncoder returns true if the named encoder is available
+func (c *Capabilities) HasEncoder(name string) bool { return hasCodec(c.Encoders, name) }
+
+// HasDecoder returns true if the named decoder is available
+func (c *Capabilities) HasDecoder(name string) bool { return hasCodec(c.Decoders, name) }
+
+func hasCodec(codecs []Codec, name string) bool {
+	for _, codec := range codecs {
+		if codec.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasFilter returns true if the named filter is available
+func (c *Capabilities) HasFilter(name string) bool {
+	for _, filter := range c.Filters {
+		if filter.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasMuxer returns true if the named format can be written
+func (c *Capabilities) HasMuxer(name string) bool {
+	return c.hasFormat(name, func(cf ContainerFormat) bool { return cf.Mux })
+}
+
+// HasDemuxer returns true if the named format can be read
+func (c *Capabilities) HasDemuxer(name string) bool {
+	return c.hasFormat(name, func(cf ContainerFormat) bool { return cf.Demux })
+}
+
+func (c *Capabilities) hasFormat(name string, supported func(ContainerFormat) bool) bool {
+	for _, format := range c.Formats {
+		for _, n := range strings.Split(format.Name, ",") {
+			if n == name && supported(format) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasInputProtocol returns true if the named protocol (such as "http") can be read
+func (c *Capabilities) HasInputProtocol(name string) bool {
+	return contains(c.InputProtocols, name)
+}
+
+// HasOutputProtocol returns true if the named protocol can be written
+func (c *Capabilities) HasOutputProtocol(name string) bool {
+	return contains(c.OutputProtocols, name)
+}
+
+// HasPixelFormat returns true if the named pixel format is available
+func (c *Capabilities) HasPixelFormat(name string) bool {
+	for _, pf := range c.PixelFormats {
+		if pf.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// requirement is something a transcode job needs ffmpeg to support
+type requirement struct {
+	kind string
+	name string
+}
+
+// supports returns an UnsupportedError if the capability is missing
+func (c *Capabilities) supports(r requirement) error {
+	has := map[string]func(string) bool{
+		"encoder":         c.HasEncoder,
+		"decoder":         c.HasDecoder,
+		"filter":          c.HasFilter,
+		"muxer":           c.HasMuxer,
+		"demuxer":         c.HasDemuxer,
+		"input protocol":  c.HasInputProtocol,
+		"output protocol": c.HasOutputProtocol,
+		"pixel format":    c.HasPixelFormat,
+	}[r.kind]
+
+	if has != nil && !has(r.name) {
+		return &UnsupportedError{Kind: r.kind, Name: r.name}
+	}
+	return nil
+}
+
+// require records that the job needs ffmpeg to support the named capability
+func (job *transcodeJob) require(kind, name string) {
+	job.requirements = append(job.requirements, requirement{kind, name})
+}
+
+// verify returns an UnsupportedError for the first requirement of the job that
+// ffmpeg does not support
+func (job *transcodeJob) verify(ctx context.Context) error {
+	c, err := job.binaries.LoadCapabilities(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range job.requirements {
+		if err := c.supports(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterNames returns the names of the filters used in a filter graph description
+func filterNames(graph string) (names []string) {
+	for _, filter := range splitFilters(graph) {
+		// drop the link labels and the arguments
+		filter = strings.TrimSpace(filter)
+		for strings.HasPrefix(filter, "[") && strings.Contains(filter, "]") {
+			filter = strings.TrimSpace(filter[strings.Index(filter, "]")+1:])
+		}
+
+		if i := strings.IndexAny(filter, "=@["); i >= 0 {
+			filter = filter[:i]
+		}
+
+		if filter = strings.TrimSpace(filter); filter != "" {
+			names = append(names, filter)
+		}
+	}
+	return names
+}
+
+// splitFilters splits a filter graph description into filters at the commas and
+// semicolons that separate them.  Like ffmpeg, text between single quotes is taken
+// literally and a backslash outside quotes escapes the next character
+func splitFilters(graph string) (filters []string) {
+	start, quoted, escaped := 0, false, false
+	for i, r := range graph {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && !quoted:
+			escaped = true
+		case r == '\'':
+			quoted = !quoted
+		case !quoted && (r == ',' || r == ';'):
+			filters = append(filters, graph[start:i])
+			start = i + 1
+		}
+	}
+	return append(filters, graph[start:])
+}
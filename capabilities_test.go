@@ -0,0 +1,303 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mh-orange/cmd"
+)
+
+// capabilitiesCmd creates processes that write testdata/<flag>1.txt to stdout
+// when run with a single flag such as -encoders, other processes are TestCmd
+// processes
+type capabilitiesCmd struct {
+	cmd.Command
+	runs int
+}
+
+func (cc *capabilitiesCmd) Process() cmd.Process {
+	return &capabilitiesProcess{Process: cc.Command.Process(), cmd: cc}
+}
+
+type capabilitiesProcess struct {
+	cmd.Process
+	cmd    *capabilitiesCmd
+	args   []string
+	stdout []io.Writer
+}
+
+func (cp *capabilitiesProcess) AppendArgs(args ...string) {
+	cp.args = append(cp.args, args...)
+	cp.Process.AppendArgs(args...)
+}
+
+func (cp *capabilitiesProcess) Stdout(writer io.Writer) {
+	cp.stdout = append(cp.stdout, writer)
+	cp.Process.Stdout(writer)
+}
+
+func (cp *capabilitiesProcess) flag() bool {
+	return len(cp.args) == 1 && strings.HasPrefix(cp.args[0], "-")
+}
+
+func (cp *capabilitiesProcess) Wait() error {
+	if cp.flag() {
+		return nil
+	}
+	return cp.Process.Wait()
+}
+
+func (cp *capabilitiesProcess) Start() error {
+	if !cp.flag() {
+		return cp.Process.Start()
+	}
+
+	cp.cmd.runs++
+	output, err := ioutil.ReadFile("testdata/" + strings.TrimPrefix(cp.args[0], "-") + "1.txt")
+	if err == nil {
+		_, err = io.MultiWriter(cp.stdout...).Write(output)
+	}
+	return err
+}
+
+func resetCapabilities() {
+	capabilitiesCache.Lock()
+	capabilitiesCache.entries = make(map[string]*capabilitiesEntry)
+	capabilitiesCache.Unlock()
+}
+
+// cacheCapabilities stores the capabilities in the cache under the key
+func cacheCapabilities(key string, c *Capabilities) {
+	entry := &capabilitiesEntry{done: make(chan struct{}), capabilities: c}
+	close(entry.done)
+	capabilitiesCache.Lock()
+	capabilitiesCache.entries[key] = entry
+	capabilitiesCache.Unlock()
+}
+
+// hangingCmd is a capabilitiesCmd whose processes do not finish until released
+type hangingCmd struct {
+	capabilitiesCmd
+	path    string
+	release chan struct{}
+}
+
+func (hc *hangingCmd) Path() string { return hc.path }
+
+func (hc *hangingCmd) Process() cmd.Process {
+	return &hangingProcess{Process: hc.capabilitiesCmd.Process(), release: hc.release}
+}
+
+type hangingProcess struct {
+	cmd.Process
+	release chan struct{}
+}
+
+func (hp *hangingProcess) Wait() error {
+	<-hp.release
+	return hp.Process.Wait()
+}
+
+func TestLoadCapabilitiesConcurrent(t *testing.T) {
+	defer resetCapabilities()
+	resetCapabilities()
+
+	hung := &hangingCmd{capabilitiesCmd{Command: &cmd.TestCmd{}}, "hung", make(chan struct{})}
+	go loadCapabilities(context.Background(), hung)
+	defer close(hung.release)
+
+	// wait for the hung build to start loading
+	for loading := false; !loading; time.Sleep(time.Millisecond) {
+		capabilitiesCache.Lock()
+		_, loading = capabilitiesCache.entries["hung"]
+		capabilitiesCache.Unlock()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := loadCapabilities(context.Background(), &capabilitiesCmd{Command: &cmd.TestCmd{}})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("loading one build was held up by another")
+	}
+
+	// callers waiting on the hung build give up when their context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := loadCapabilities(ctx, hung); err != context.DeadlineExceeded {
+		t.Errorf("wanted %v got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestLoadCapabilities(t *testing.T) {
+	oldFfmpeg := Ffmpeg
+	defer resetCapabilities()
+	resetCapabilities()
+
+	cc := &capabilitiesCmd{Command: &cmd.TestCmd{}}
+	Ffmpeg = cc
+	c, err := LoadCapabilities(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if c.Version != "4.4.2-0ubuntu0.22.04.1" {
+		t.Errorf("want version 4.4.2-0ubuntu0.22.04.1 got %q", c.Version)
+	}
+
+	if !contains(c.Configuration, "--enable-libx264") || len(c.Configuration) != 7 {
+		t.Errorf("unexpected configuration %v", c.Configuration)
+	}
+
+	if c.Libraries["libavcodec"] != "58.134.100" || c.Libraries["libswscale"] != "5.9.100" || len(c.Libraries) != 7 {
+		t.Errorf("unexpected libraries %v", c.Libraries)
+	}
+
+	wantEncoder := Codec{Name: "libx264", Description: "libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)", Type: Video, Flags: "V....D"}
+	if len(c.Encoders) != 5 || !reflect.DeepEqual(wantEncoder, c.Encoders[0]) || c.Encoders[4].Type != Subtitle {
+		t.Errorf("unexpected encoders %+v", c.Encoders)
+	}
+
+	if len(c.Decoders) != 5 || c.Decoders[2].Name != "ac3" || c.Decoders[2].Type != Audio {
+		t.Errorf("unexpected decoders %+v", c.Decoders)
+	}
+
+	wantFilter := Filter{Name: "bwdif", Description: "Deinterlace the input image.", Inputs: "V", Outputs: "V", Flags: "TS."}
+	if len(c.Filters) != 6 || !reflect.DeepEqual(wantFilter, c.Filters[1]) {
+		t.Errorf("unexpected filters %+v", c.Filters)
+	}
+
+	wantFormat := ContainerFormat{Name: "matroska,webm", Description: "Matroska / WebM", Demux: true}
+	if len(c.Formats) != 6 || !reflect.DeepEqual(wantFormat, c.Formats[1]) {
+		t.Errorf("unexpected formats %+v", c.Formats)
+	}
+
+	if !reflect.DeepEqual([]string{"file", "http", "https", "pipe"}, c.InputProtocols) || !reflect.DeepEqual([]string{"file", "pipe", "rtmp"}, c.OutputProtocols) {
+		t.Errorf("unexpected protocols %v %v", c.InputProtocols, c.OutputProtocols)
+	}
+
+	wantPixFmt := PixelFormat{Name: "yuv420p10le", Components: 3, BitsPerPixel: 15, Input: true, Output: true}
+	if len(c.PixelFormats) != 4 || !reflect.DeepEqual(wantPixFmt, c.PixelFormats[1]) || c.PixelFormats[2].Input {
+		t.Errorf("unexpected pixel formats %+v", c.PixelFormats)
+	}
+
+	runs := cc.runs
+	if _, err := LoadCapabilities(context.Background()); err != nil || cc.runs != runs {
+		t.Errorf("expected capabilities to be cached")
+	}
+	Ffmpeg = oldFfmpeg
+}
+
+func TestCapabilitiesHas(t *testing.T) {
+	oldFfmpeg := Ffmpeg
+	defer resetCapabilities()
+	resetCapabilities()
+
+	Ffmpeg = &capabilitiesCmd{Command: &cmd.TestCmd{}}
+	c, err := LoadCapabilities(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	Ffmpeg = oldFfmpeg
+
+	tests := []struct {
+		has  func(string) bool
+		name string
+		want bool
+	}{
+		{c.HasEncoder, "libx264", true},
+		{c.HasEncoder, "libx265", false},
+		{c.HasDecoder, "hevc", true},
+		{c.HasDecoder, "libx264", false},
+		{c.HasFilter, "bwdif", true},
+		{c.HasFilter, "yadif", false},
+		{c.HasMuxer, "mp4", true},
+		{c.HasMuxer, "webm", false},
+		{c.HasMuxer, "aac", false},
+		{c.HasDemuxer, "webm", true},
+		{c.HasDemuxer, "mp4", false},
+		{c.HasInputProtocol, "https", true},
+		{c.HasInputProtocol, "rtmp", false},
+		{c.HasOutputProtocol, "rtmp", true},
+		{c.HasPixelFormat, "yuv420p", true},
+		{c.HasPixelFormat, "nv12", false},
+	}
+
+	for i, test := range tests {
+		if got := test.has(test.name); got != test.want {
+			t.Errorf("tests[%d] %s: want %v got %v", i, test.name, test.want, got)
+		}
+	}
+}
+
+func TestFilterNames(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"idet", []string{"idet"}},
+		{"bwdif=mode=1:parity=0", []string{"bwdif"}},
+		{"scale=1280:-2,format=yuv420p", []string{"scale", "format"}},
+		{"[0:v]split=2[a][b];[a]scale=640:-2[out1];[b] drawtext@label=text=x [out2]", []string{"split", "scale", "drawtext"}},
+		{"select='eq(pict_type,I)',scale=640:-2", []string{"select", "scale"}},
+		{`drawtext=text='a\,b';null`, []string{"drawtext", "null"}},
+		{`drawtext=text=a\,b\;c,null`, []string{"drawtext", "null"}},
+		{`drawtext=text='it'\''s, here',null`, []string{"drawtext", "null"}},
+	}
+
+	for i, test := range tests {
+		if got := filterNames(test.input); !reflect.DeepEqual(test.want, got) {
+			t.Errorf("tests[%d] want %v got %v", i, test.want, got)
+		}
+	}
+}
+
+func TestCapabilityCheckOption(t *testing.T) {
+	oldFfmpeg := Ffmpeg
+	defer resetCapabilities()
+	resetCapabilities()
+
+	u, _ := url.Parse("rtmp://live.net/stream")
+	tests := []struct {
+		name    string
+		options []TranscoderOption
+		wantErr error
+	}{
+		{"supported", []TranscoderOption{VideoFilterOption("bwdif=mode=1"), Output(DefaultH264(), OutputFormat("mp4"))}, nil},
+		{"unknown filter", []TranscoderOption{VideoFilterOption("yadif")}, &UnsupportedError{"filter", "yadif"}},
+		{"unknown encoder", []TranscoderOption{Output(AudioCodecOption("libopus"))}, &UnsupportedError{"encoder", "libopus"}},
+		{"unknown protocol", []TranscoderOption{Input(InputURL(u))}, &UnsupportedError{"input protocol", "rtmp"}},
+		{"copy", []TranscoderOption{Output(CopyOutput())}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			Ffmpeg = &capabilitiesCmd{Command: &cmd.TestCmd{}}
+			job, err := NewTranscoder().Transcode(append(test.options, CapabilityCheckOption())...)
+			if !reflect.DeepEqual(test.wantErr, err) {
+				t.Errorf("want %v got %v", test.wantErr, err)
+			}
+
+			if err != nil && !errors.Is(err, ErrUnsupported) {
+				t.Errorf("expected error to be ErrUnsupported")
+			} else if err == nil {
+				job.Wait()
+			}
+		})
+	}
+	Ffmpeg = oldFfmpeg
+}
@@ -1,6 +1,28 @@
 package ffmpeg
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// truncationTolerance is how much shorter than the container duration the decoded
+// duration can be before an input is considered truncated
+const truncationTolerance = Second
+
+var (
+	decodeErrorPtrn  = regexp.MustCompile(`(?i)error|invalid|corrupt|concealing|missing|illegal|overread|damaged`)
+	componentStrPtrn = regexp.MustCompile(`#\d+:(\d+)`)
+	messageStrPtrn   = regexp.MustCompile(`(?i)stream #?(?:\d+:)?(\d+)`)
+)
+
 type CheckTranscoder struct {
+	// Binaries selects the ffmpeg and ffprobe executables, if nil then the package
+	// Ffmpeg and Ffprobe commands are used
+	Binaries *Binaries
 }
 
 func NewCheckTranscoder() *CheckTranscoder {
@@ -8,16 +30,206 @@ func NewCheckTranscoder() *CheckTranscoder {
 }
 
 func (ct *CheckTranscoder) Check(input TranscoderInput) (TranscodeJob, error) {
+	return ct.CheckContext(context.Background(), input)
+}
+
+// CheckContext is like Check but the ffmpeg process is killed if the context is
+// done before the check completes
+func (ct *CheckTranscoder) CheckContext(ctx context.Context, input TranscoderInput) (TranscodeJob, error) {
 	transcoder := NewTranscoder()
+	transcoder.Binaries = ct.Binaries
 	options := append([]TranscoderOption{input}, DiscardOption())
-	return transcoder.Transcode(options...)
+	return transcoder.TranscodeContext(ctx, options...)
 }
 
 func Check(input TranscoderInput) (string, error) {
+	return CheckContext(context.Background(), input)
+}
+
+// CheckContext decodes the input, discarding the output, and returns the log.  If
+// the context is done before decoding completes then ctx.Err() is returned
+func CheckContext(ctx context.Context, input TranscoderInput) (string, error) {
 	transcoder := NewCheckTranscoder()
-	job, err := transcoder.Check(input)
+	job, err := transcoder.CheckContext(ctx, input)
 	if err == nil {
 		err = job.Wait()
 	}
 	return job.Log(), err
 }
+
+// DecodeError is an error ffmpeg logged while decoding the input
+type DecodeError struct {
+	// Stream is the index of the input stream that could not be decoded, or -1 if
+	// the stream is not known
+	Stream int
+
+	// Position is the approximate position in the input where the error occurred
+	Position Time
+
+	// Component is the ffmpeg component (usually the decoder) that logged the error
+	Component string
+
+	// Message is the error message
+	Message string
+}
+
+// CheckReport is the result of decoding an input to check its integrity
+type CheckReport struct {
+	// Verdict is the overall result of the check
+	Verdict Verdict
+
+	// Reason explains the verdict, it is empty when the verdict is VerdictOK
+	Reason string
+
+	// Errors are the decode errors found in the log.  Only the errors in the kept
+	// part of the log are included (see LogSizeOption)
+	Errors []DecodeError
+
+	// CorruptFrames is the number of corrupt frames per input stream index.  Frames
+	// whose stream is not known are counted under -1
+	CorruptFrames map[int]int
+
+	// Anomalies are all the anomalies ffmpeg logged while decoding
+	Anomalies []Anomaly
+
+	// Duration is the expected length of the input, this is zero when the input
+	// could not be probed
+	Duration Time
+
+	// DecodedDuration is how much of the input was decoded
+	DecodedDuration Time
+
+	// Truncated is true if DecodedDuration falls short of Duration
+	Truncated bool
+
+	// Err is the error ffmpeg failed with, if any
+	Err error
+
+	// Log is the ffmpeg log
+	Log string
+}
+
+// Report decodes the input, discarding the output, and reports on any problems found.
+// An error is only returned when ffmpeg could not be started or the context is done,
+// a failure to decode is reported with VerdictUnreadable
+func (ct *CheckTranscoder) Report(ctx context.Context, input TranscoderInput) (*CheckReport, error) {
+	job, err := ct.CheckContext(ctx, input)
+	if err == nil {
+		err = job.Wait()
+	}
+
+	var te *TranscodeError
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	} else if err != nil && !errors.As(err, &te) {
+		return nil, err
+	}
+
+	result := job.Result()
+	report := &CheckReport{
+		CorruptFrames:   make(map[int]int),
+		Anomalies:       result.Anomalies,
+		Duration:        input.input().duration(),
+		DecodedDuration: result.Time,
+		Err:             err,
+		Log:             job.Log(),
+	}
+
+	codecs := streamCodecs(input.input().fi)
+	entries := job.LogEntries()
+	for entries.Scan() {
+		if de, ok := decodeError(entries.Entry(), codecs); ok {
+			report.Errors = append(report.Errors, de)
+		}
+	}
+
+	for _, anomaly := range result.Anomalies {
+		if anomaly.Type == CorruptFrame {
+			report.CorruptFrames[streamIndex(anomaly.Stream)] += anomaly.Count
+		}
+	}
+
+	report.Truncated = report.Duration > 0 && report.DecodedDuration < report.Duration-truncationTolerance
+	if err != nil {
+		report.Verdict = VerdictUnreadable
+		report.Reason = err.Error()
+	} else if report.Truncated {
+		report.Verdict = VerdictTruncated
+		report.Reason = fmt.Sprintf("decoded %v of %v", report.DecodedDuration, report.Duration)
+	} else if len(report.Errors) > 0 {
+		report.Verdict = VerdictDamaged
+		report.Reason = fmt.Sprintf("%d decode errors, first at %v: %s", len(report.Errors), report.Errors[0].Position, report.Errors[0].Message)
+	} else if len(report.CorruptFrames) > 0 {
+		report.Verdict = VerdictDamaged
+		report.Reason = "corrupt frames decoded"
+	}
+	return report, nil
+}
+
+// streamCodecs maps codec names to the index of the stream using the codec, codecs
+// used by more than one stream are left out since the stream is ambiguous
+func streamCodecs(fi *FileInfo) map[string]int {
+	codecs := make(map[string]int)
+	if fi == nil {
+		return codecs
+	}
+
+	var streams []StreamInfo
+	for _, stream := range fi.VideoStreams {
+		streams = append(streams, stream.StreamInfo)
+	}
+
+	for _, stream := range fi.AudioStreams {
+		streams = append(streams, stream.StreamInfo)
+	}
+
+	for _, stream := range fi.SubtitleStreams {
+		streams = append(streams, stream.StreamInfo)
+	}
+
+	for _, stream := range streams {
+		if _, found := codecs[stream.CodecName]; found {
+			codecs[stream.CodecName] = -1
+		} else {
+			codecs[stream.CodecName] = stream.Index
+		}
+	}
+	return codecs
+}
+
+// streamIndex parses an input stream index, -1 is returned if the stream is empty or
+// is not an input stream index
+func streamIndex(stream string) int {
+	index, err := strconv.Atoi(stream)
+	if err != nil || index < 0 {
+		return -1
+	}
+	return index
+}
+
+// decodeError determines if the log entry is a decoding error, and which input
+// stream it belongs to
+func decodeError(entry LogEntry, codecs map[string]int) (DecodeError, bool) {
+	de := DecodeError{Stream: -1, Position: entry.Position, Component: entry.Component, Message: entry.Message}
+	if entry.Level < LogError && !decodeErrorPtrn.MatchString(entry.Message) {
+		return de, false
+	}
+
+	// the output of a check is discarded by the null muxer, its complaints are
+	// about timestamps rather than decoding
+	if entry.Component == "null" || strings.HasPrefix(entry.Component, "out#") {
+		return de, false
+	}
+
+	// lines without a component are only decode errors if they name the stream
+	if match := componentStrPtrn.FindStringSubmatch(entry.Component); match != nil {
+		de.Stream = streamIndex(match[1])
+	} else if match := messageStrPtrn.FindStringSubmatch(entry.Message); match != nil {
+		de.Stream = streamIndex(match[1])
+	} else if index, found := codecs[entry.Component]; found {
+		de.Stream = index
+	} else if entry.Component == "" {
+		return de, false
+	}
+	return de, true
+}
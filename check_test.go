@@ -0,0 +1,112 @@
+package ffmpeg
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mh-orange/cmd"
+)
+
+func TestDecodeError(t *testing.T) {
+	codecs := map[string]int{"h264": 0, "ac3": 1, "aac": -1}
+	tests := []struct {
+		line   string
+		want   bool
+		stream int
+	}{
+		{"Input #0, mpegts, from 'broken.ts':", false, -1},
+		{"[h264 @ 0x5600] error while decoding MB 52 33, bytestream -7", true, 0},
+		{"[ac3 @ 0x5600] [error] frame sync error", true, 1},
+		{"[aac @ 0x5600] Input buffer exhausted before END element found", false, -1},
+		{"[aac @ 0x5600] [error] Input buffer exhausted before END element found", true, -1},
+		{"[vist#0:2/hevc @ 0x5600] [error] Decoding error: Invalid data found when processing input", true, 2},
+		{"Error while decoding stream #0:1: Invalid data found when processing input", true, 1},
+		{"corrupt decoded frame in stream 3", true, 3},
+		{"[error] Conversion failed!", false, -1},
+		{"[null @ 0x5600] Application provided invalid, non monotonically increasing dts to muxer in stream 1", false, -1},
+		{"corrupt decoded frame in stream 99999999999999999999", true, -1},
+	}
+
+	for i, test := range tests {
+		de, got := decodeError(parseLogEntry(time.Time{}, test.line), codecs)
+		if got != test.want {
+			t.Errorf("tests[%d] want %v got %v", i, test.want, got)
+		} else if got && de.Stream != test.stream {
+			t.Errorf("tests[%d] want stream %d got %d", i, test.stream, de.Stream)
+		}
+	}
+}
+
+func TestStreamIndex(t *testing.T) {
+	tests := []struct {
+		stream string
+		want   int
+	}{
+		{"0", 0},
+		{"3", 3},
+		{"", -1},
+		{"0:1", -1},
+		{"-2", -1},
+	}
+
+	for _, test := range tests {
+		if got := streamIndex(test.stream); got != test.want {
+			t.Errorf("stream %q want %d got %d", test.stream, test.want, got)
+		}
+	}
+}
+
+func TestCheckTranscoderReport(t *testing.T) {
+	oldFfmpeg := Ffmpeg
+	u, _ := url.Parse("http://video.net/broken.ts")
+
+	tests := []struct {
+		name     string
+		stderr   string
+		hint     Time
+		want     Verdict
+		errors   int
+		corrupt  map[int]int
+		position Time
+	}{
+		{"ok", "testdata/transcode1.txt", 10 * Second, VerdictOK, 0, map[int]int{}, 0},
+		{"damaged", "testdata/check1.txt", 10 * Second, VerdictDamaged, 4, map[int]int{0: 3}, 5005 * Millisecond},
+		{"truncated", "testdata/check1.txt", 60 * Second, VerdictTruncated, 4, map[int]int{0: 3}, 5005 * Millisecond},
+		{"unknown duration", "testdata/transcode1.txt", 0, VerdictOK, 0, map[int]int{}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stderr, err := ioutil.ReadFile(test.stderr)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			Ffmpeg = &cmd.TestCmd{Stderr: stderr}
+
+			input := Input(InputURL(u), DurationHintOption(test.hint))
+			report, err := NewCheckTranscoder().Report(context.Background(), input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if report.Verdict != test.want {
+				t.Errorf("want %v got %v (%s)", test.want, report.Verdict, report.Reason)
+			}
+
+			if len(report.Errors) != test.errors {
+				t.Errorf("want %d errors got %+v", test.errors, report.Errors)
+			} else if test.errors > 0 && report.Errors[0].Position != test.position {
+				t.Errorf("want position %v got %v", test.position, report.Errors[0].Position)
+			}
+
+			if !reflect.DeepEqual(test.corrupt, report.CorruptFrames) {
+				t.Errorf("want corrupt frames %v got %v", test.corrupt, report.CorruptFrames)
+			}
+		})
+	}
+	Ffmpeg = oldFfmpeg
+}
@@ -1,23 +1,162 @@
 package ffmpeg
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
 
 	"github.com/mh-orange/cmd"
 )
 
 var (
-	Ffmpeg  = cmd.New("ffmpeg", "-hide_banner", "-nostdin", "-nostats", "-progress", "/dev/stderr")
-	Ffprobe = cmd.New("ffprobe", "-hide_banner", "-v", "error", "-print_format", "json", "-sexagesimal", "-show_format", "-show_streams", "-show_chapters", "-show_programs")
+	ffmpegArgs  = []string{"-hide_banner", "-nostdin", "-nostats", "-progress", "/dev/stderr"}
+	ffprobeArgs = []string{"-hide_banner", "-v", "error", "-print_format", "json", "-sexagesimal", "-show_format", "-show_streams", "-show_chapters", "-show_programs"}
 )
 
-func init() {
-	for _, c := range []cmd.Command{Ffmpeg, Ffprobe} {
-		path, err := exec.LookPath(c.Path())
-		if err != nil {
-			log.Printf("%v no such file or directory", c.Path())
+// Ffmpeg and Ffprobe are the commands used when no Binaries are given.  The
+// executables are looked up in the PATH each time they are started, and a missing
+// executable is reported as ErrFfmpegNotFound or ErrFfprobeNotFound
+var (
+	Ffmpeg  = LocalExecutor{}.Command("ffmpeg", ffmpegArgs...)
+	Ffprobe = LocalExecutor{}.Command("ffprobe", ffprobeArgs...)
+)
+
+// Binaries selects the ffmpeg and ffprobe commands to run, so that several ffmpeg
+// builds can be used side by side.  A nil *Binaries, or a nil command, means the
+// package Ffmpeg and Ffprobe commands are used
+type Binaries struct {
+	Ffmpeg  cmd.Command
+	Ffprobe cmd.Command
+}
+
+// Executor creates the commands that run ffmpeg and ffprobe.  The processes of the
+// commands are started, piped, waited for and killed through the cmd.Process interface.
+// Processes that also have a Signal(os.Signal) error method can be paused, resumed and
+// stopped gracefully
+type Executor interface {
+	// Command returns a command that runs the program ("ffmpeg" or "ffprobe") with
+	// the given arguments ahead of any arguments added to its processes
+	Command(program string, args ...string) cmd.Command
+}
+
+// LocalExecutor runs programs on this host.  Programs without a directory are looked
+// up in the PATH when their processes are started.  The processes can be signaled, so
+// local jobs can be paused, resumed and stopped gracefully
+type LocalExecutor struct{}
+
+// Command returns a cmd.Command for the program
+func (LocalExecutor) Command(program string, args ...string) cmd.Command {
+	return &localCommand{path: program, args: args}
+}
+
+// NewBinaries returns Binaries that run the ffmpeg and ffprobe executables at the
+// given paths.  Paths without a directory are looked up in the PATH
+func NewBinaries(ffmpeg, ffprobe string) *Binaries {
+	executor := LocalExecutor{}
+	return &Binaries{
+		Ffmpeg:  executor.Command(ffmpeg, ffmpegArgs...),
+		Ffprobe: executor.Command(ffprobe, ffprobeArgs...),
+	}
+}
+
+// NewExecutorBinaries returns Binaries that run ffmpeg and ffprobe with the executor
+func NewExecutorBinaries(executor Executor) *Binaries {
+	return &Binaries{
+		Ffmpeg:  executor.Command("ffmpeg", ffmpegArgs...),
+		Ffprobe: executor.Command("ffprobe", ffprobeArgs...),
+	}
+}
+
+func (b *Binaries) ffmpeg() cmd.Command {
+	if b == nil || b.Ffmpeg == nil {
+		return Ffmpeg
+	}
+	return b.Ffmpeg
+}
+
+func (b *Binaries) ffprobe() cmd.Command {
+	if b == nil || b.Ffprobe == nil {
+		return Ffprobe
+	}
+	return b.Ffprobe
+}
+
+// Stat is like the package StatContext function but uses the selected ffprobe
+func (b *Binaries) Stat(ctx context.Context, filename string) (*FileInfo, error) {
+	return statContext(ctx, b.ffprobe(), filename)
+}
+
+// StatReader is like the package StatReaderContext function but uses the selected ffprobe
+func (b *Binaries) StatReader(ctx context.Context, reader io.Reader) (*FileInfo, error) {
+	return statReaderContext(ctx, b.ffprobe(), reader)
+}
+
+// ProbeFrames is like the package ProbeFrames function but uses the selected ffprobe
+func (b *Binaries) ProbeFrames(ctx context.Context, filename string, options ...ProbeOption) (*ProbeReader, error) {
+	return newProbeReader(ctx, b.ffprobe(), filename, []string{"-show_frames"}, options...)
+}
+
+// ProbePackets is like the package ProbePackets function but uses the selected ffprobe
+func (b *Binaries) ProbePackets(ctx context.Context, filename string, options ...ProbeOption) (*ProbeReader, error) {
+	return newProbeReader(ctx, b.ffprobe(), filename, []string{"-show_packets"}, options...)
+}
+
+// KeyFrames is like the package KeyFrames function but uses the selected ffprobe
+func (b *Binaries) KeyFrames(ctx context.Context, filename string, stream int) ([]Time, error) {
+	return keyFrames(ctx, b.ffprobe(), filename, stream)
+}
+
+// AnalyzeGOP is like the package AnalyzeGOP function but uses the selected ffprobe
+func (b *Binaries) AnalyzeGOP(ctx context.Context, filename string, stream int) (*GOPInfo, error) {
+	return analyzeGOP(ctx, b.ffprobe(), filename, stream)
+}
+
+// LoadCapabilities is like the package LoadCapabilities function but uses the selected ffmpeg
+func (b *Binaries) LoadCapabilities(ctx context.Context) (*Capabilities, error) {
+	return loadCapabilities(ctx, b.ffmpeg())
+}
+
+// notFound replaces the error from starting a process with the given error when the
+// executable does not exist
+func notFound(err error, missing error) error {
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %v", missing, err)
+	}
+	return err
+}
+
+// watchContext kills the process if the context is done before the done
+// channel is closed
+func watchContext(ctx context.Context, proc cmd.Process, done <-chan struct{}) {
+	go func() {
+		select {
+		case <-ctx.Done():
+			proc.Kill()
+		case <-done:
 		}
-		c.SetPath(path)
+	}()
+}
+
+// signaler is implemented by processes that can be sent a signal
+type signaler interface {
+	Signal(os.Signal) error
+}
+
+// the processes of the executors in this package can be signaled, which is what
+// TranscodeJob.Stop, Pause and Resume rely on
+var (
+	_ signaler = (*localProcess)(nil)
+	_ signaler = (*containerProcess)(nil)
+)
+
+// signalProcess sends the signal to the process, ErrSignalUnsupported is returned
+// if the process cannot be signaled or the signal is not available on this platform
+func signalProcess(proc cmd.Process, sig os.Signal) error {
+	if s, ok := proc.(signaler); ok && sig != nil {
+		return s.Signal(sig)
 	}
+	return ErrSignalUnsupported
 }
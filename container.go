@@ -0,0 +1,232 @@
+package ffmpeg
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/mh-orange/cmd"
+)
+
+// ErrRootPath is returned when starting a container for a file in the host's root
+// directory, which would mean mounting the whole host file system in the container
+var ErrRootPath = errors.New("files in the root directory cannot be mounted in a container")
+
+// ContainerExecutor is an Executor that runs ffmpeg and ffprobe inside a container
+// image using the docker (or podman) command line, so that an exact ffmpeg build
+// can be used without installing it on the host.  The program is run as the
+// container's entrypoint, so images whose entrypoint is already ffmpeg can run
+// ffprobe too.
+//
+// Arguments that are absolute paths are rewritten to paths inside the container
+// and their directories are bind mounted.  The working directory is mounted as
+// the container's working directory so that relative paths work as they are.
+// Paths embedded in other arguments (such as filter options) are not rewritten
+type ContainerExecutor struct {
+	// Runtime is the container command line tool, such as "docker" or "podman"
+	Runtime string
+
+	// Image is the container image that ffmpeg and ffprobe are run from
+	Image string
+
+	// RunArgs are additional arguments for "run", such as "--cpus=2" or "--user=1000"
+	RunArgs []string
+}
+
+// NewContainerExecutor returns a ContainerExecutor that uses the runtime ("docker" or
+// "podman") to run ffmpeg and ffprobe from the image
+func NewContainerExecutor(runtime, image string) *ContainerExecutor {
+	return &ContainerExecutor{Runtime: runtime, Image: image}
+}
+
+// Command returns a cmd.Command whose processes run the program in a new container
+func (ce *ContainerExecutor) Command(program string, args ...string) cmd.Command {
+	return &containerCommand{executor: ce, program: program, args: args}
+}
+
+type containerCommand struct {
+	executor *ContainerExecutor
+	program  string
+	args     []string
+}
+
+func (cc *containerCommand) Path() string        { return cc.program }
+func (cc *containerCommand) SetPath(path string) { cc.program = path }
+
+// cacheKey identifies the ffmpeg build by the image it runs from
+func (cc *containerCommand) cacheKey() string {
+	return cc.executor.Runtime + " " + cc.executor.Image + " " + cc.program
+}
+
+func (cc *containerCommand) Process() cmd.Process {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return &containerProcess{
+		executor: cc.executor,
+		name:     fmt.Sprintf("%s-%s", filepath.Base(cc.program), hex.EncodeToString(id)),
+		args:     append([]string{cc.program}, cc.args...),
+	}
+}
+
+// containerProcess collects the arguments and stdio of the program and starts the
+// container once they are all known
+type containerProcess struct {
+	executor *ContainerExecutor
+	name     string
+	args     []string
+	stdin    io.Reader
+	stdout   []io.Writer
+	stderr   []io.Writer
+
+	mu   sync.Mutex
+	proc cmd.Process
+}
+
+func (cp *containerProcess) AppendArgs(args ...string) { cp.args = append(cp.args, args...) }
+func (cp *containerProcess) Stdin(reader io.Reader)    { cp.stdin = reader }
+func (cp *containerProcess) Stdout(writer io.Writer)   { cp.stdout = append(cp.stdout, writer) }
+func (cp *containerProcess) Stderr(writer io.Writer)   { cp.stderr = append(cp.stderr, writer) }
+
+// Args returns the complete container command line
+func (cp *containerProcess) Args() []string {
+	args, _ := cp.runArgs()
+	return append([]string{cp.executor.Runtime}, args...)
+}
+
+// runArgs builds the arguments of "run", mounting the directories of absolute paths
+// and rewriting those paths.  ErrRootPath is returned, along with the arguments, if
+// a path is in the root directory
+func (cp *containerProcess) runArgs() ([]string, error) {
+	var err error
+	args := []string{"run", "--rm", "--name", cp.name}
+	if cp.stdin != nil {
+		args = append(args, "-i")
+	}
+
+	if wd, err := os.Getwd(); err == nil {
+		args = append(args, "-v", wd+":/work", "-w", "/work")
+	}
+	args = append(args, cp.executor.RunArgs...)
+
+	mounts := make(map[string]string)
+	program := make([]string, len(cp.args)-1)
+	for i, arg := range cp.args[1:] {
+		program[i] = arg
+		if !filepath.IsAbs(arg) || strings.HasPrefix(arg, "/dev/") {
+			continue
+		}
+
+		dir, file := filepath.Split(filepath.Clean(arg))
+		if dir == filepath.VolumeName(dir)+string(filepath.Separator) {
+			err = fmt.Errorf("%w: %s", ErrRootPath, arg)
+			continue
+		}
+
+		mount, found := mounts[dir]
+		if !found {
+			mount = fmt.Sprintf("/mnt/%d", len(mounts))
+			mounts[dir] = mount
+			args = append(args, "-v", fmt.Sprintf("%s:%s", filepath.Clean(dir), mount))
+		}
+		program[i] = mount + "/" + file
+	}
+
+	args = append(args, "--entrypoint", cp.args[0], cp.executor.Image)
+	return append(args, program...), err
+}
+
+func (cp *containerProcess) Start() error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	args, err := cp.runArgs()
+	if err != nil {
+		return err
+	}
+
+	proc := LocalExecutor{}.Command(cp.executor.Runtime, args...).Process()
+	if cp.stdin != nil {
+		proc.Stdin(cp.stdin)
+	}
+
+	for _, writer := range cp.stdout {
+		proc.Stdout(writer)
+	}
+
+	for _, writer := range cp.stderr {
+		proc.Stderr(writer)
+	}
+
+	// the container only exists (and can be killed) once the process has started
+	err = proc.Start()
+	if err == nil {
+		cp.proc = proc
+	}
+	return err
+}
+
+func (cp *containerProcess) Wait() error {
+	proc := cp.process()
+	if proc == nil {
+		return errNotStarted
+	}
+	return proc.Wait()
+}
+
+// Kill removes the container as well as killing the container command line tool,
+// which would otherwise leave the container running
+func (cp *containerProcess) Kill() error {
+	proc := cp.process()
+	if proc == nil {
+		return errNotStarted
+	}
+
+	cp.control("kill")
+	return proc.Kill()
+}
+
+// Signal sends the signal to ffmpeg inside the container.  Containers cannot be
+// sent SIGSTOP and SIGCONT, so those pause and unpause the container instead
+func (cp *containerProcess) Signal(sig os.Signal) error {
+	switch {
+	case cp.process() == nil:
+		return errNotStarted
+	case sig == nil:
+		return ErrSignalUnsupported
+	case sig == sigStop:
+		return cp.control("pause")
+	case sig == sigCont:
+		return cp.control("unpause")
+	}
+
+	number, ok := sig.(syscall.Signal)
+	if !ok {
+		return ErrSignalUnsupported
+	}
+	return cp.control("kill", fmt.Sprintf("--signal=%d", int(number)))
+}
+
+// control runs a container command line tool command (such as kill or pause) on
+// the container
+func (cp *containerProcess) control(command string, args ...string) error {
+	args = append(append([]string{command}, args...), cp.name)
+	proc := LocalExecutor{}.Command(cp.executor.Runtime, args...).Process()
+	err := proc.Start()
+	if err == nil {
+		err = proc.Wait()
+	}
+	return err
+}
+
+func (cp *containerProcess) process() cmd.Process {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.proc
+}
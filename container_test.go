@@ -0,0 +1,112 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestContainerProcessArgs(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		stdin bool
+		args  []string
+		want  []string
+	}{
+		{"relative paths", false, []string{"-i", "input.mkv", "output.mp4"}, []string{"--entrypoint", "ffmpeg", "image:1", "-i", "input.mkv", "output.mp4"}},
+		{"absolute paths", false, []string{"-i", "/media/in/input.mkv", "-progress", "/dev/stderr", "/media/out/output.mp4", "/media/in/second.mkv"}, []string{"-v", "/media/in:/mnt/0", "-v", "/media/out:/mnt/1", "--entrypoint", "ffmpeg", "image:1", "-i", "/mnt/0/input.mkv", "-progress", "/dev/stderr", "/mnt/1/output.mp4", "/mnt/0/second.mkv"}},
+		{"urls", false, []string{"-i", "http://example.com/input.mkv", "output.mp4"}, []string{"--entrypoint", "ffmpeg", "image:1", "-i", "http://example.com/input.mkv", "output.mp4"}},
+		{"stdin", true, []string{"-i", "pipe:0", "output.mp4"}, []string{"-i", "-v", wd + ":/work", "-w", "/work", "--cpus=2", "--entrypoint", "ffmpeg", "image:1", "-i", "pipe:0", "output.mp4"}},
+	}
+
+	executor := NewContainerExecutor("podman", "image:1")
+	executor.RunArgs = []string{"--cpus=2"}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			proc := executor.Command("ffmpeg").Process()
+			if test.stdin {
+				proc.Stdin(strings.NewReader(""))
+			}
+			proc.AppendArgs(test.args...)
+
+			got := proc.Args()
+			if got[0] != "podman" || got[1] != "run" || got[2] != "--rm" || got[3] != "--name" || !strings.HasPrefix(got[4], "ffmpeg-") {
+				t.Errorf("Unexpected container command %v", got)
+			}
+
+			if tail := got[len(got)-len(test.want):]; !reflect.DeepEqual(test.want, tail) {
+				t.Errorf("wanted %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestContainerExecutorBinaries(t *testing.T) {
+	binaries := NewExecutorBinaries(NewContainerExecutor("docker", "image:1"))
+	args := binaries.Ffprobe.Process().Args()
+	want := append([]string{"--entrypoint", "ffprobe", "image:1"}, ffprobeArgs...)
+	if tail := args[len(args)-len(want):]; !reflect.DeepEqual(want, tail) {
+		t.Errorf("wanted %v got %v", want, args)
+	}
+}
+
+func TestContainerCapabilitiesCache(t *testing.T) {
+	defer resetCapabilities()
+	resetCapabilities()
+
+	local := &Capabilities{Version: "local"}
+	cacheCapabilities("ffmpeg", local)
+
+	executor := NewContainerExecutor("no-such-runtime", "image:1")
+	c, err := NewExecutorBinaries(executor).LoadCapabilities(context.Background())
+	if c == local || !errors.Is(err, ErrFfmpegNotFound) {
+		t.Errorf("wanted %v got %v and %v", ErrFfmpegNotFound, c, err)
+	}
+
+	other := NewContainerExecutor("no-such-runtime", "image:2").Command("ffmpeg").(*containerCommand)
+	if key := executor.Command("ffmpeg").(*containerCommand).cacheKey(); key == other.cacheKey() {
+		t.Errorf("images share the cache key %q", key)
+	}
+}
+
+func TestContainerProcessRootPath(t *testing.T) {
+	proc := NewContainerExecutor("no-such-runtime", "image:1").Command("ffmpeg").Process()
+	proc.AppendArgs("-i", "/media/in/input.mkv", "/out.mp4")
+	if err := proc.Start(); !errors.Is(err, ErrRootPath) {
+		t.Errorf("wanted %v got %v", ErrRootPath, err)
+	}
+
+	for _, arg := range proc.Args() {
+		if strings.HasPrefix(arg, "/:") {
+			t.Errorf("root directory mounted in %v", proc.Args())
+		}
+	}
+}
+
+func TestContainerProcessNotStarted(t *testing.T) {
+	proc := NewContainerExecutor("no-such-runtime", "image:1").Command("ffmpeg").Process()
+	if err := proc.Start(); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("wanted %v got %v", exec.ErrNotFound, err)
+	}
+
+	if err := proc.Wait(); err != errNotStarted {
+		t.Errorf("wanted %v got %v", errNotStarted, err)
+	}
+
+	if err := proc.Kill(); err != errNotStarted {
+		t.Errorf("wanted %v got %v", errNotStarted, err)
+	}
+
+	if err := proc.(signaler).Signal(os.Interrupt); err != errNotStarted {
+		t.Errorf("wanted %v got %v", errNotStarted, err)
+	}
+}
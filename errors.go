@@ -0,0 +1,171 @@
+package ffmpeg
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// ErrFfmpegNotFound is returned when the ffmpeg executable cannot be found
+	ErrFfmpegNotFound = errors.New("ffmpeg not found")
+
+	// ErrFfprobeNotFound is returned when the ffprobe executable cannot be found
+	ErrFfprobeNotFound = errors.New("ffprobe not found")
+
+	// ErrInputNotFound is the cause of a TranscodeError when ffmpeg could not find an input
+	ErrInputNotFound = errors.New("input not found")
+
+	// ErrPermissionDenied is the cause of a TranscodeError when ffmpeg could not read an input
+	// or write an output due to permissions
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrInvalidData is the cause of a TranscodeError when the input could not be demuxed or decoded
+	ErrInvalidData = errors.New("invalid data found when processing input")
+
+	// ErrUnknownEncoder is the cause of a TranscodeError when the requested encoder is not
+	// available in the ffmpeg build
+	ErrUnknownEncoder = errors.New("unknown encoder")
+
+	// ErrUnsupportedCodec is the cause of a TranscodeError when the output container cannot
+	// hold one of the output codecs
+	ErrUnsupportedCodec = errors.New("codec not supported in container")
+
+	// ErrNonMonotonicDTS is the cause of a TranscodeError when the muxer rejected packets because
+	// their decoding timestamps were not increasing.  This is common when copying streams from
+	// damaged files
+	ErrNonMonotonicDTS = errors.New("non monotonically increasing dts")
+
+	// ErrMuxingQueueFull is the cause of a TranscodeError when too many packets were buffered
+	// waiting for another output stream
+	ErrMuxingQueueFull = errors.New("too many packets buffered for output stream")
+
+	// ErrNoSpace is the cause of a TranscodeError when the output device is full
+	ErrNoSpace = errors.New("no space left on device")
+
+	// ErrKilled is the cause of a TranscodeError when the ffmpeg process was canceled or killed
+	ErrKilled = errors.New("transcode killed")
+
+	// ErrStalled is the cause of a TranscodeError when the ffmpeg process was killed by
+	// the watchdog because it stopped making progress
+	ErrStalled = errors.New("transcode stalled")
+
+	// ErrStopped is returned by TranscodeJob.Wait when the job was stopped early with
+	// TranscodeJob.Stop and ffmpeg exited on its own, having finalised the output
+	ErrStopped = errors.New("transcode stopped")
+
+	// ErrSignalUnsupported is returned when pausing or resuming a job whose process
+	// cannot be sent signals
+	ErrSignalUnsupported = errors.New("process does not support signals")
+)
+
+// TranscodeErrorLogLines is the number of lines, from the end of the log, kept in a
+// TranscodeError
+const TranscodeErrorLogLines = 10
+
+var errorCauses = []struct {
+	substr string
+	cause  error
+}{
+	{"no such file or directory", ErrInputNotFound},
+	{"permission denied", ErrPermissionDenied},
+	{"invalid data found when processing input", ErrInvalidData},
+	{"unknown encoder", ErrUnknownEncoder},
+	{"encoder not found", ErrUnknownEncoder},
+	{"codec not currently supported in container", ErrUnsupportedCodec},
+	{"could not find tag for codec", ErrUnsupportedCodec},
+	{"non monotonically increasing dts", ErrNonMonotonicDTS},
+	{"non-monotonous dts", ErrNonMonotonicDTS},
+	{"too many packets buffered for output stream", ErrMuxingQueueFull},
+	{"no space left on device", ErrNoSpace},
+}
+
+// TranscodeError is returned by TranscodeJob.Wait and TranscodeJob.Err when the ffmpeg process
+// fails.  The Cause is one of the Err* values in this package (or nil if the failure could
+// not be classified) so callers can use errors.Is to branch on the type of failure:
+//
+//	if errors.Is(job.Wait(), ffmpeg.ErrUnknownEncoder) {
+//		...
+//	}
+type TranscodeError struct {
+	// ExitCode is the exit code of the ffmpeg process, or -1 if the process was
+	// terminated by a signal or the exit code is not known
+	ExitCode int
+
+	// Command is the full command line, as returned by TranscodeJob.Inspect
+	Command string
+
+	// Log is the tail of the ffmpeg log
+	Log []string
+
+	// Cause is the classified reason for the failure
+	Cause error
+
+	// Err is the error returned when waiting for the process
+	Err error
+}
+
+func newTranscodeError(err error, command string, log []string, killed bool) *TranscodeError {
+	te := &TranscodeError{
+		ExitCode: -1,
+		Command:  command,
+		Err:      err,
+	}
+
+	if len(log) > TranscodeErrorLogLines {
+		log = log[len(log)-TranscodeErrorLogLines:]
+	}
+	te.Log = append([]string(nil), log...)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		te.ExitCode = exitErr.ExitCode()
+	}
+
+	if killed {
+		te.Cause = ErrKilled
+	} else {
+		te.Cause = classify(te.Log)
+		if te.Cause == nil && exitErr != nil && te.ExitCode == -1 {
+			te.Cause = ErrKilled
+		}
+	}
+	return te
+}
+
+// classify searches the log lines, most recent first, for a known failure
+func classify(log []string) error {
+	for i := len(log) - 1; i >= 0; i-- {
+		line := strings.ToLower(log[i])
+		for _, ec := range errorCauses {
+			if strings.Contains(line, ec.substr) {
+				return ec.cause
+			}
+		}
+	}
+	return nil
+}
+
+// Error returns the last two lines of the log, which is where ffmpeg reports the
+// reason for a failure.  If there is no log then the process error is returned
+func (te *TranscodeError) Error() string {
+	if len(te.Log) >= 2 {
+		return strings.TrimSpace(strings.Join(te.Log[len(te.Log)-2:], "\n"))
+	} else if len(te.Log) == 1 {
+		return strings.TrimSpace(te.Log[0])
+	} else if te.Err != nil {
+		return te.Err.Error()
+	}
+	return "transcode failed"
+}
+
+// Unwrap returns the Cause so that errors.Is can be used to check the Cause
+func (te *TranscodeError) Unwrap() error {
+	return te.Cause
+}
+
+// As lets errors.As find the error returned when waiting for the process (for
+// instance an *exec.ExitError) as well as the Cause
+func (te *TranscodeError) As(target interface{}) bool {
+	return te.Err != nil && errors.As(te.Err, target)
+}
@@ -0,0 +1,96 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"testing"
+)
+
+func TestTranscodeErrorClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		log    []string
+		killed bool
+		want   error
+	}{
+		{"not found", []string{"missing.mkv: No such file or directory"}, false, ErrInputNotFound},
+		{"permission", []string{"/root/out.mkv: Permission denied"}, false, ErrPermissionDenied},
+		{"invalid data", []string{"[matroska,webm @ 0x55d0c1f0] EBML header parsing failed", "broken.mkv: Invalid data found when processing input"}, false, ErrInvalidData},
+		{"unknown encoder", []string{"Unknown encoder 'libfdk_aac'"}, false, ErrUnknownEncoder},
+		{"unsupported codec", []string{"[mp4 @ 0x5580] Could not find tag for codec pcm_s16le in stream #1, codec not currently supported in container", "Could not write header for output file #0 (incorrect codec parameters ?): Invalid argument"}, false, ErrUnsupportedCodec},
+		{"dts", []string{"[mp4 @ 0x55d0] Application provided invalid, non monotonically increasing dts to muxer in stream 1: 6063 >= 6063", "av_interleaved_write_frame(): Invalid argument"}, false, ErrNonMonotonicDTS},
+		{"muxing queue", []string{"Too many packets buffered for output stream 0:1.", "Conversion failed!"}, false, ErrMuxingQueueFull},
+		{"no space", []string{"av_interleaved_write_frame(): No space left on device"}, false, ErrNoSpace},
+		{"killed", []string{"frame=  100"}, true, ErrKilled},
+		{"unknown", []string{"Conversion failed!"}, false, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := error(newTranscodeError(io.EOF, "ffmpeg -i foo", test.log, test.killed))
+			var te *TranscodeError
+			if !errors.As(err, &te) {
+				t.Fatalf("want *TranscodeError got %T", err)
+			}
+
+			if te.Cause != test.want {
+				t.Errorf("want cause %v got %v", test.want, te.Cause)
+			}
+
+			if test.want != nil && !errors.Is(err, test.want) {
+				t.Errorf("want errors.Is(%v) to be true", test.want)
+			}
+
+			if te.ExitCode != -1 {
+				t.Errorf("want exit code -1 got %d", te.ExitCode)
+			}
+		})
+	}
+}
+
+func TestTranscodeErrorError(t *testing.T) {
+	tests := []struct {
+		log  []string
+		want string
+	}{
+		{[]string{"one", "two", "three"}, "two\nthree"},
+		{[]string{"one "}, "one"},
+		{nil, io.EOF.Error()},
+	}
+
+	for _, test := range tests {
+		te := newTranscodeError(io.EOF, "", test.log, false)
+		if te.Error() != test.want {
+			t.Errorf("want %q got %q", test.want, te.Error())
+		}
+	}
+}
+
+func TestTranscodeErrorLogTail(t *testing.T) {
+	log := make([]string, TranscodeErrorLogLines*2)
+	te := newTranscodeError(io.EOF, "", log, false)
+	if len(te.Log) != TranscodeErrorLogLines {
+		t.Errorf("want %d lines got %d", TranscodeErrorLogLines, len(te.Log))
+	}
+}
+
+func TestTranscodeErrorAs(t *testing.T) {
+	exitErr := &exec.ExitError{}
+	err := fmt.Errorf("check: %w", &TranscodeError{Cause: ErrInvalidData, Err: exitErr})
+
+	var te *TranscodeError
+	if !errors.As(err, &te) {
+		t.Errorf("wanted a *TranscodeError in %v", err)
+	}
+
+	var gotExitErr *exec.ExitError
+	if !errors.As(err, &gotExitErr) || gotExitErr != exitErr {
+		t.Errorf("wanted %v got %v", exitErr, gotExitErr)
+	}
+
+	if !errors.Is(err, ErrInvalidData) {
+		t.Errorf("wanted %v in %v", ErrInvalidData, err)
+	}
+}
@@ -0,0 +1,225 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mh-orange/cmd"
+)
+
+// ErrNoKeyFrames is returned when a video stream does not contain any key frames
+var ErrNoKeyFrames = errors.New("no key frames found in stream")
+
+// GOPInfo summarizes the Group Of Pictures structure of a video stream
+type GOPInfo struct {
+	// KeyFrames is the presentation time of every key frame in the stream.  These
+	// are the points where the stream can be cut without re-encoding
+	KeyFrames []Time
+
+	// Lengths is the number of frames in each GOP, in stream order.  The last GOP
+	// is usually truncated by the end of the stream
+	Lengths []int
+
+	// MinLength is the length of the shortest complete GOP
+	MinLength int
+
+	// MaxLength is the length of the longest complete GOP
+	MaxLength int
+
+	// AvgLength is the average length of the complete GOPs
+	AvgLength float64
+
+	// OpenGOPs is the number of GOPs containing frames that reference the previous
+	// GOP.  Cutting at the key frame of an open GOP will leave undecodable frames
+	OpenGOPs int
+
+	// MaxBFrames is the largest number of consecutive B frames in the stream
+	MaxBFrames int
+
+	// Pattern is the sequence of picture types (in presentation order) of the first
+	// complete GOP, for instance "IBBPBBPBBPBB"
+	Pattern string
+}
+
+// Closed is true when none of the GOPs reference frames in a previous GOP
+func (gi *GOPInfo) Closed() bool { return gi.OpenGOPs == 0 }
+
+// KeyFrameBefore returns the time of the last key frame at or before t.  This is the
+// position to pass to StartOption when cutting without re-encoding
+func (gi *GOPInfo) KeyFrameBefore(t Time) Time {
+	i := sort.Search(len(gi.KeyFrames), func(i int) bool { return gi.KeyFrames[i] > t })
+	if i == 0 {
+		return 0
+	}
+	return gi.KeyFrames[i-1]
+}
+
+// KeyFrameAfter returns the time of the first key frame at or after t.  If there are
+// no key frames after t then the last key frame is returned, and if there are no key
+// frames at all then zero is returned
+func (gi *GOPInfo) KeyFrameAfter(t Time) Time {
+	if len(gi.KeyFrames) == 0 {
+		return 0
+	}
+
+	i := sort.Search(len(gi.KeyFrames), func(i int) bool { return gi.KeyFrames[i] >= t })
+	if i == len(gi.KeyFrames) {
+		i--
+	}
+	return gi.KeyFrames[i]
+}
+
+// gopAnalyzer accumulates packets (in decode order) and frames (in presentation
+// order) to build a GOPInfo
+type gopAnalyzer struct {
+	info GOPInfo
+
+	keyPTS  int64
+	hasPTS  bool
+	inGOP   bool
+	open    bool
+	length  int
+	pattern []string
+	done    bool
+	bFrames int
+}
+
+func (ga *gopAnalyzer) packet(packet *PacketInfo) {
+	if packet.KeyFrame() {
+		ga.endGOP()
+		ga.info.KeyFrames = append(ga.info.KeyFrames, packet.PTSTime)
+		ga.keyPTS = packet.PTS
+		ga.hasPTS = packet.HasPTS
+		ga.inGOP = true
+		ga.length = 1
+	} else if ga.inGOP {
+		ga.length++
+		// packets without a PTS say nothing about the GOP being open
+		if ga.hasPTS && packet.HasPTS && packet.PTS < ga.keyPTS {
+			ga.open = true
+		}
+	}
+}
+
+func (ga *gopAnalyzer) endGOP() {
+	if ga.inGOP {
+		ga.info.Lengths = append(ga.info.Lengths, ga.length)
+		if ga.open {
+			ga.info.OpenGOPs++
+		}
+	}
+	ga.open = false
+}
+
+func (ga *gopAnalyzer) frame(frame *FrameInfo) {
+	if frame.PictType == "B" {
+		ga.bFrames++
+		if ga.bFrames > ga.info.MaxBFrames {
+			ga.info.MaxBFrames = ga.bFrames
+		}
+	} else {
+		ga.bFrames = 0
+	}
+
+	if !ga.done {
+		if frame.KeyFrame && len(ga.pattern) > 0 {
+			ga.info.Pattern = strings.Join(ga.pattern, "")
+			ga.done = true
+		} else if frame.KeyFrame || len(ga.pattern) > 0 {
+			ga.pattern = append(ga.pattern, frame.PictType)
+		}
+	}
+}
+
+func (ga *gopAnalyzer) result() (*GOPInfo, error) {
+	ga.endGOP()
+	ga.inGOP = false
+	if len(ga.info.KeyFrames) == 0 {
+		return nil, ErrNoKeyFrames
+	}
+
+	sort.Slice(ga.info.KeyFrames, func(i, j int) bool { return ga.info.KeyFrames[i] < ga.info.KeyFrames[j] })
+
+	complete := ga.info.Lengths
+	if len(complete) > 1 {
+		complete = complete[:len(complete)-1]
+	}
+
+	ga.info.MinLength = complete[0]
+	total := 0
+	for _, length := range complete {
+		if length < ga.info.MinLength {
+			ga.info.MinLength = length
+		}
+
+		if length > ga.info.MaxLength {
+			ga.info.MaxLength = length
+		}
+		total += length
+	}
+	ga.info.AvgLength = float64(total) / float64(len(complete))
+
+	if ga.info.Pattern == "" {
+		ga.info.Pattern = strings.Join(ga.pattern, "")
+	}
+	return &ga.info, nil
+}
+
+// KeyFrames returns the presentation time of every key frame in the video stream with
+// the given index.  Only packets are inspected, the stream is not decoded
+func KeyFrames(ctx context.Context, filename string, stream int) ([]Time, error) {
+	return keyFrames(ctx, Ffprobe, filename, stream)
+}
+
+func keyFrames(ctx context.Context, ffprobe cmd.Command, filename string, stream int) ([]Time, error) {
+	pr, err := newProbeReader(ctx, ffprobe, filename, []string{"-show_packets"}, SelectStreamsOption(strconv.Itoa(stream)))
+	if err != nil {
+		return nil, err
+	}
+
+	analyzer := &gopAnalyzer{}
+	for pr.Scan() {
+		analyzer.packet(pr.Packet())
+	}
+
+	if pr.Err() != nil {
+		return nil, pr.Err()
+	}
+
+	info, err := analyzer.result()
+	if err != nil {
+		return nil, err
+	}
+	return info.KeyFrames, nil
+}
+
+// AnalyzeGOP determines the GOP structure of the video stream with the given index.
+// Since the picture types are only known once the frames are decoded, this will take
+// about as long as decoding the entire stream
+func AnalyzeGOP(ctx context.Context, filename string, stream int) (*GOPInfo, error) {
+	return analyzeGOP(ctx, Ffprobe, filename, stream)
+}
+
+func analyzeGOP(ctx context.Context, ffprobe cmd.Command, filename string, stream int) (*GOPInfo, error) {
+	pr, err := newProbeReader(ctx, ffprobe, filename, []string{"-show_packets", "-show_frames"}, SelectStreamsOption(strconv.Itoa(stream)))
+	if err != nil {
+		return nil, err
+	}
+
+	analyzer := &gopAnalyzer{}
+	for pr.Scan() {
+		if pr.Packet() != nil {
+			analyzer.packet(pr.Packet())
+		} else if pr.Frame() != nil {
+			analyzer.frame(pr.Frame())
+		}
+	}
+
+	if pr.Err() != nil {
+		return nil, pr.Err()
+	}
+	return analyzer.result()
+}
@@ -0,0 +1,105 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGOPAnalyzer(t *testing.T) {
+	// decode order: closed GOP, open GOP (leading B frames), truncated GOP
+	packets := []struct {
+		pts int64
+		key bool
+	}{
+		{0, true}, {3, false}, {1, false}, {2, false}, {6, false}, {4, false}, {5, false},
+		{9, true}, {7, false}, {8, false}, {12, false}, {10, false}, {11, false},
+		{15, true}, {16, false},
+	}
+	frames := "IBBPBBPBBIBBPBIP"
+
+	analyzer := &gopAnalyzer{}
+	for _, p := range packets {
+		flags := "__"
+		if p.key {
+			flags = "K_"
+		}
+		analyzer.packet(&PacketInfo{PTS: p.pts, HasPTS: true, PTSTime: Time(p.pts) * Second, Flags: flags})
+	}
+
+	for _, pictType := range frames {
+		analyzer.frame(&FrameInfo{KeyFrame: pictType == 'I', PictType: string(pictType)})
+	}
+
+	got, err := analyzer.result()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &GOPInfo{
+		KeyFrames:  []Time{0, 9 * Second, 15 * Second},
+		Lengths:    []int{7, 6, 2},
+		MinLength:  6,
+		MaxLength:  7,
+		AvgLength:  6.5,
+		OpenGOPs:   1,
+		MaxBFrames: 2,
+		Pattern:    "IBBPBBPBB",
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+
+	if got.Closed() {
+		t.Errorf("want open GOP structure")
+	}
+
+	tests := []struct {
+		input      Time
+		wantBefore Time
+		wantAfter  Time
+	}{
+		{0, 0, 0},
+		{10 * Second, 9 * Second, 15 * Second},
+		{100 * Second, 15 * Second, 15 * Second},
+	}
+
+	for _, test := range tests {
+		if before := got.KeyFrameBefore(test.input); before != test.wantBefore {
+			t.Errorf("KeyFrameBefore(%v) want %v got %v", test.input, test.wantBefore, before)
+		}
+
+		if after := got.KeyFrameAfter(test.input); after != test.wantAfter {
+			t.Errorf("KeyFrameAfter(%v) want %v got %v", test.input, test.wantAfter, after)
+		}
+	}
+}
+
+func TestGOPAnalyzerNoKeyFrames(t *testing.T) {
+	analyzer := &gopAnalyzer{}
+	analyzer.packet(&PacketInfo{Flags: "__"})
+	if _, err := analyzer.result(); err != ErrNoKeyFrames {
+		t.Errorf("want %v got %v", ErrNoKeyFrames, err)
+	}
+
+	info := &GOPInfo{}
+	if before, after := info.KeyFrameBefore(Second), info.KeyFrameAfter(Second); before != 0 || after != 0 {
+		t.Errorf("want 0 and 0 got %v and %v", before, after)
+	}
+}
+
+func TestGOPAnalyzerMissingPTS(t *testing.T) {
+	analyzer := &gopAnalyzer{}
+	analyzer.packet(&PacketInfo{PTS: 10, HasPTS: true, PTSTime: 10 * Second, Flags: "K_"})
+	analyzer.packet(&PacketInfo{Flags: "__"})
+	analyzer.packet(&PacketInfo{PTS: 11, HasPTS: true, PTSTime: 11 * Second, Flags: "__"})
+
+	info, err := analyzer.result()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !info.Closed() {
+		t.Errorf("want closed GOP got %d open GOPs", info.OpenGOPs)
+	}
+}
@@ -16,15 +16,108 @@ package ffmpeg
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
+
+	"github.com/mh-orange/cmd"
 )
 
-// ProgramInfo is information about programs and their streams, returned by ffprobe
+// ProgramInfo is information about programs and their streams, returned by ffprobe.
+// Programs are found in containers such as MPEG-TS where several services (channels)
+// are multiplexed together
 type ProgramInfo struct {
+	// ID is the program identifier
+	ID int `json:"program_id"`
+
+	// Number is the program number from the Program Association Table
+	Number int `json:"program_num"`
+
+	// PMTPID is the PID carrying the Program Map Table for the program
+	PMTPID int `json:"pmt_pid"`
+
+	// PCRPID is the PID carrying the Program Clock Reference for the program
+	PCRPID int `json:"pcr_pid"`
+
+	// StartTime is the start time of the program
+	StartTime Time `json:"start_time"`
+
+	// EndTime is the end time of the program
+	EndTime Time `json:"end_time"`
+
+	// Tags is the metadata attached to the program (service_name, service_provider, etc)
+	Tags Tags `json:"tags"`
+
+	// VideoStreams is the list of video streams belonging to the program.  These are the
+	// same objects found in FileInfo.VideoStreams
+	VideoStreams []*VideoStreamInfo `json:"-"`
+
+	// AudioStreams is the list of audio streams belonging to the program.  These are the
+	// same objects found in FileInfo.AudioStreams
+	AudioStreams []*AudioStreamInfo `json:"-"`
+
+	// SubtitleStreams is the list of subtitle streams belonging to the program.  These are
+	// the same objects found in FileInfo.SubtitleStreams
+	SubtitleStreams []*SubtitleStreamInfo `json:"-"`
+
+	// DataStreams is the list of data streams belonging to the program.  These are the
+	// same objects found in FileInfo.DataStreams
+	DataStreams []*DataStreamInfo `json:"-"`
+}
+
+// ServiceName returns the service_name tag of the program
+func (pi *ProgramInfo) ServiceName() string { return pi.Tags.Get("service_name") }
+
+// StreamIndexes returns the indexes of all the streams belonging to the program
+func (pi *ProgramInfo) StreamIndexes() (indexes []int) {
+	for _, vs := range pi.VideoStreams {
+		indexes = append(indexes, vs.Index)
+	}
+
+	for _, as := range pi.AudioStreams {
+		indexes = append(indexes, as.Index)
+	}
+
+	for _, ss := range pi.SubtitleStreams {
+		indexes = append(indexes, ss.Index)
+	}
+
+	for _, ds := range pi.DataStreams {
+		indexes = append(indexes, ds.Index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// Tags are the metadata key/value pairs that ffprobe reports for streams, chapters
+// and formats (language, title, encoder, handler_name, etc)
+type Tags map[string]string
+
+// Get returns the value of the named tag.  Keys are matched case insensitively since
+// some muxers write tags in upper case (TITLE instead of title).  An empty string is
+// returned if the tag is not present
+func (t Tags) Get(key string) string {
+	if value, found := t[key]; found {
+		return value
+	}
+
+	for k, value := range t {
+		if strings.EqualFold(k, key) {
+			return value
+		}
+	}
+	return ""
 }
 
+// Language returns the language tag, this is usually an ISO 639-2 code such as "eng"
+func (t Tags) Language() string { return t.Get("language") }
+
+// Title returns the title tag
+func (t Tags) Title() string { return t.Get("title") }
+
 // StreamInfo is the information from ffprobe about individual streams contained in some
 // media
 type StreamInfo struct {
@@ -64,8 +157,17 @@ type StreamInfo struct {
 	Duration Time `json:"duration"`
 
 	Disposition DispositionInfo `json:"disposition"`
+
+	// Tags is the metadata attached to the stream
+	Tags Tags `json:"tags"`
 }
 
+// Language returns the language tag of the stream
+func (si *StreamInfo) Language() string { return si.Tags.Language() }
+
+// Title returns the title tag of the stream
+func (si *StreamInfo) Title() string { return si.Tags.Title() }
+
 type VideoStreamInfo struct {
 	StreamInfo
 
@@ -104,6 +206,28 @@ type SubtitleStreamInfo struct {
 	Height int `json:"height"`
 }
 
+// DataStreamInfo is a data stream, such as a QuickTime timecode track
+type DataStreamInfo struct {
+	StreamInfo
+}
+
+// IsTimecode indicates if the data stream is a QuickTime timecode (tmcd) track
+func (ds *DataStreamInfo) IsTimecode() bool { return ds.CodecTagString == "tmcd" }
+
+// Timecode returns the starting timecode of the stream (for instance "01:00:00:00")
+func (ds *DataStreamInfo) Timecode() string { return ds.Tags.Get("timecode") }
+
+// AttachmentStreamInfo is an attached file, such as a font embedded in a matroska container
+type AttachmentStreamInfo struct {
+	StreamInfo
+}
+
+// Filename returns the name of the attached file
+func (as *AttachmentStreamInfo) Filename() string { return as.Tags.Get("filename") }
+
+// MimeType returns the mime type of the attached file
+func (as *AttachmentStreamInfo) MimeType() string { return as.Tags.Get("mimetype") }
+
 type DispositionInfo struct {
 	Default         int `json:"default"`
 	Dub             int `json:"dub"`
@@ -123,8 +247,14 @@ type ChapterInfo struct {
 	ID    int  `json:"id"`
 	Start Time `json:"start_time"`
 	End   Time `json:"end_time"`
+
+	// Tags is the metadata attached to the chapter
+	Tags Tags `json:"tags"`
 }
 
+// Title returns the title tag of the chapter
+func (ci *ChapterInfo) Title() string { return ci.Tags.Title() }
+
 // FormatInfo is everything we know about the format (container) of the file
 type FormatInfo struct {
 	// Filename is the URL/Filename that was passed to the ffprobe command
@@ -151,8 +281,14 @@ type FormatInfo struct {
 	// ProbeScore is a value between 0 and 100 that indicates how well ffprobe did when
 	// trying to determine what kind of media was contained in the file
 	ProbeScore int `json:"probe_score"`
+
+	// Tags is the metadata attached to the container
+	Tags Tags `json:"tags"`
 }
 
+// Title returns the title tag of the container
+func (fi *FormatInfo) Title() string { return fi.Tags.Title() }
+
 // FileInfo is informational data about a given media file
 type FileInfo struct {
 	// Programs is the list of information about all the programs contained in the media
@@ -167,6 +303,12 @@ type FileInfo struct {
 	// SubtitleStreams is the list of information about all the subtitles in the media
 	SubtitleStreams []*SubtitleStreamInfo
 
+	// DataStreams is the list of information about all the data streams in the media
+	DataStreams []*DataStreamInfo
+
+	// AttachmentStreams is the list of information about all the attachments in the media
+	AttachmentStreams []*AttachmentStreamInfo
+
 	// Chapters is a list of all the chapters contained in the media
 	Chapters []*ChapterInfo `json:"Chapters"`
 
@@ -194,7 +336,7 @@ func (fi *FileInfo) IsVideo() bool {
 // the FileInfo object
 func (fi *FileInfo) UnmarshalJSON(data []byte) error {
 	tmp := struct {
-		Programs []*ProgramInfo
+		Programs []json.RawMessage
 		Streams  []json.RawMessage
 		Chapters []*ChapterInfo
 		Format   FormatInfo
@@ -203,7 +345,6 @@ func (fi *FileInfo) UnmarshalJSON(data []byte) error {
 	err := json.Unmarshal(data, &tmp)
 
 	if err == nil {
-		fi.Programs = tmp.Programs
 		fi.Chapters = tmp.Chapters
 		fi.Format = tmp.Format
 		for _, str := range tmp.Streams {
@@ -229,6 +370,18 @@ func (fi *FileInfo) UnmarshalJSON(data []byte) error {
 					if err == nil {
 						fi.SubtitleStreams = append(fi.SubtitleStreams, ss)
 					}
+				case Data:
+					ds := &DataStreamInfo{StreamInfo: *si}
+					err = json.Unmarshal(str, ds)
+					if err == nil {
+						fi.DataStreams = append(fi.DataStreams, ds)
+					}
+				case Attachment:
+					as := &AttachmentStreamInfo{StreamInfo: *si}
+					err = json.Unmarshal(str, as)
+					if err == nil {
+						fi.AttachmentStreams = append(fi.AttachmentStreams, as)
+					}
 				}
 			}
 
@@ -238,24 +391,144 @@ func (fi *FileInfo) UnmarshalJSON(data []byte) error {
 		}
 	}
 
+	for _, prog := range tmp.Programs {
+		if err != nil {
+			break
+		}
+
+		pi := &ProgramInfo{}
+		streams := struct {
+			Streams []struct {
+				Index int `json:"index"`
+			} `json:"streams"`
+		}{}
+
+		err = json.Unmarshal(prog, pi)
+		if err == nil {
+			err = json.Unmarshal(prog, &streams)
+		}
+
+		if err == nil {
+			for _, stream := range streams.Streams {
+				fi.link(pi, stream.Index)
+			}
+			fi.Programs = append(fi.Programs, pi)
+		}
+	}
+
 	return err
 }
 
+// link adds the stream with the given index to the program
+func (fi *FileInfo) link(pi *ProgramInfo, index int) {
+	for _, vs := range fi.VideoStreams {
+		if vs.Index == index {
+			pi.VideoStreams = append(pi.VideoStreams, vs)
+		}
+	}
+
+	for _, as := range fi.AudioStreams {
+		if as.Index == index {
+			pi.AudioStreams = append(pi.AudioStreams, as)
+		}
+	}
+
+	for _, ss := range fi.SubtitleStreams {
+		if ss.Index == index {
+			pi.SubtitleStreams = append(pi.SubtitleStreams, ss)
+		}
+	}
+
+	for _, ds := range fi.DataStreams {
+		if ds.Index == index {
+			pi.DataStreams = append(pi.DataStreams, ds)
+		}
+	}
+}
+
 // Stat will pass the filename to ffprobe and parse the output.  If no error occurs, then
 // a FileInfo containing all the stream, program and format information is returned
 func Stat(filename string) (fi *FileInfo, err error) {
-	proc := Ffprobe.Process()
+	return StatContext(context.Background(), filename)
+}
+
+// StatContext is like Stat but the ffprobe process is killed if the context is
+// canceled or its deadline passes before probing completes.  In that case ctx.Err()
+// is returned
+func StatContext(ctx context.Context, filename string) (fi *FileInfo, err error) {
+	return statContext(ctx, Ffprobe, filename)
+}
+
+func statContext(ctx context.Context, ffprobe cmd.Command, filename string) (fi *FileInfo, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	proc := ffprobe.Process()
 	proc.AppendArgs(filename)
+	return probe(ctx, proc)
+}
+
+// StatReader is like Stat except the media is read from the reader and sent to ffprobe
+// using STDIN.  If the reader is also an io.Seeker then it is returned to its original
+// position once probing is complete so the same reader can be passed to InputReader.
+// Otherwise, whatever ffprobe consumed is lost.  Note that some formats (such as MP4
+// files with the moov atom at the end) cannot be probed without seeking
+func StatReader(reader io.Reader) (*FileInfo, error) {
+	return StatReaderContext(context.Background(), reader)
+}
+
+// StatReaderContext is like StatReader but the ffprobe process is killed if the context
+// is canceled or its deadline passes before probing completes
+func StatReaderContext(ctx context.Context, reader io.Reader) (fi *FileInfo, err error) {
+	return statReaderContext(ctx, Ffprobe, reader)
+}
+
+func statReaderContext(ctx context.Context, ffprobe cmd.Command, reader io.Reader) (fi *FileInfo, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	offset := int64(0)
+	seeker, seekable := reader.(io.Seeker)
+	if seekable {
+		offset, err = seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	proc := ffprobe.Process()
+	proc.AppendArgs("-")
+	proc.Stdin(reader)
+	fi, err = probe(ctx, proc)
+
+	if seekable {
+		_, seekErr := seeker.Seek(offset, io.SeekStart)
+		if err == nil {
+			err = seekErr
+		}
+	}
+	return fi, err
+}
+
+// probe runs the ffprobe process and parses its output
+func probe(ctx context.Context, proc cmd.Process) (fi *FileInfo, err error) {
 	logWriter := bytes.NewBuffer(nil)
 	writer := bytes.NewBuffer(nil)
 	proc.Stdout(writer)
 	proc.Stderr(logWriter)
-	err = proc.Start()
+	err = notFound(proc.Start(), ErrFfprobeNotFound)
 	if err == nil {
+		done := make(chan struct{})
+		watchContext(ctx, proc, done)
 		err = proc.Wait()
+		close(done)
 		if err == nil {
 			fi = &FileInfo{}
 			err = json.Unmarshal(writer.Bytes(), fi)
+		} else if ctx.Err() != nil {
+			err = ctx.Err()
 		} else {
 			err = fmt.Errorf("%s", strings.TrimSpace(logWriter.String()))
 		}
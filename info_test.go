@@ -1,8 +1,14 @@
 package ffmpeg
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"io/ioutil"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -48,3 +54,185 @@ func TestInfoUnmarshalJSON(t *testing.T) {
 	}
 	Ffprobe = oldFfprobe
 }
+
+func TestStatContext(t *testing.T) {
+	oldFfprobe := Ffprobe
+	Ffprobe = &cmd.TestCmd{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := StatContext(ctx, "test.mkv")
+	if err != context.Canceled {
+		t.Errorf("wanted %v got %v", context.Canceled, err)
+	}
+
+	Ffprobe = oldFfprobe
+}
+
+func TestBinariesStat(t *testing.T) {
+	oldFfprobe := Ffprobe
+	Ffprobe = &cmd.TestCmd{StartErr: io.EOF}
+
+	input, err := ioutil.ReadFile("testdata/info1.json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	binaries := &Binaries{Ffprobe: &cmd.TestCmd{Stdout: input}}
+	fi, err := binaries.Stat(context.Background(), "test.mkv")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(fi.VideoStreams) == 0 {
+		t.Errorf("expected video streams")
+	}
+
+	_, err = NewBinaries("/nonexistent/ffmpeg", "/nonexistent/ffprobe").Stat(context.Background(), "test.mkv")
+	if !errors.Is(err, ErrFfprobeNotFound) {
+		t.Errorf("wanted %v got %v", ErrFfprobeNotFound, err)
+	}
+
+	Ffprobe = oldFfprobe
+}
+
+func TestInfoTags(t *testing.T) {
+	input, err := ioutil.ReadFile("testdata/info1.json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	fi := &FileInfo{}
+	err = json.Unmarshal(input, fi)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := fi.AudioStreams[0].Language(); got != "eng" {
+		t.Errorf("want eng got %q", got)
+	}
+
+	if got := fi.Chapters[1].Title(); got != "Chapter  2" {
+		t.Errorf("want %q got %q", "Chapter  2", got)
+	}
+
+	if got := fi.Format.Tags.Get("ENCODER"); got != "libmkv 0.6.4.2" {
+		t.Errorf("want %q got %q", "libmkv 0.6.4.2", got)
+	}
+
+	if got := fi.Format.Title(); got != "" {
+		t.Errorf("want empty title got %q", got)
+	}
+}
+
+func TestInfoPrograms(t *testing.T) {
+	input, err := ioutil.ReadFile("testdata/info6.json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	fi := &FileInfo{}
+	err = json.Unmarshal(input, fi)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(fi.Programs) != 2 {
+		t.Fatalf("want 2 programs got %d", len(fi.Programs))
+	}
+
+	tests := []struct {
+		number      int
+		pmtPID      int
+		pcrPID      int
+		serviceName string
+		indexes     []int
+	}{
+		{3, 48, 49, "KXYZ-HD", []int{0, 1}},
+		{4, 64, 65, "KXYZ-SD", []int{2}},
+	}
+
+	for i, test := range tests {
+		pi := fi.Programs[i]
+		if pi.Number != test.number || pi.PMTPID != test.pmtPID || pi.PCRPID != test.pcrPID {
+			t.Errorf("programs[%d] want %d/%d/%d got %d/%d/%d", i, test.number, test.pmtPID, test.pcrPID, pi.Number, pi.PMTPID, pi.PCRPID)
+		}
+
+		if pi.ServiceName() != test.serviceName {
+			t.Errorf("programs[%d] want %q got %q", i, test.serviceName, pi.ServiceName())
+		}
+
+		if got := pi.StreamIndexes(); !reflect.DeepEqual(test.indexes, got) {
+			t.Errorf("programs[%d] want %v got %v", i, test.indexes, got)
+		}
+	}
+
+	if fi.Programs[0].VideoStreams[0] != fi.VideoStreams[0] {
+		t.Errorf("expected program stream to be linked to the file stream")
+	}
+}
+
+func TestInfoDataAndAttachments(t *testing.T) {
+	fi := &FileInfo{}
+	input, err := ioutil.ReadFile("testdata/info7.json")
+	if err == nil {
+		err = json.Unmarshal(input, fi)
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(fi.AttachmentStreams) != 1 {
+		t.Fatalf("want 1 attachment got %d", len(fi.AttachmentStreams))
+	}
+
+	as := fi.AttachmentStreams[0]
+	if as.Index != 2 || as.Filename() != "OpenSans-Semibold.ttf" || as.MimeType() != "application/x-truetype-font" {
+		t.Errorf("unexpected attachment %d %q %q", as.Index, as.Filename(), as.MimeType())
+	}
+
+	fi = &FileInfo{}
+	input, err = ioutil.ReadFile("testdata/info8.json")
+	if err == nil {
+		err = json.Unmarshal(input, fi)
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(fi.DataStreams) != 1 {
+		t.Fatalf("want 1 data stream got %d", len(fi.DataStreams))
+	}
+
+	ds := fi.DataStreams[0]
+	if !ds.IsTimecode() || ds.Timecode() != "01:00:00:00" {
+		t.Errorf("want timecode 01:00:00:00 got %v %q", ds.IsTimecode(), ds.Timecode())
+	}
+}
+
+func TestStatReader(t *testing.T) {
+	oldFfprobe := Ffprobe
+
+	input, err := ioutil.ReadFile("testdata/info1.json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	Ffprobe = &cmd.TestCmd{Stdout: input}
+
+	reader := bytes.NewReader(make([]byte, 1024))
+	reader.Seek(42, io.SeekStart)
+	fi, err := StatReader(reader)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if len(fi.VideoStreams) != 1 {
+		t.Errorf("want 1 video stream got %d", len(fi.VideoStreams))
+	}
+
+	if offset, _ := reader.Seek(0, io.SeekCurrent); offset != 42 {
+		t.Errorf("want reader at offset 42 got %d", offset)
+	}
+
+	Ffprobe = oldFfprobe
+}
@@ -1,6 +1,7 @@
 package ffmpeg
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/url"
@@ -25,18 +26,55 @@ type input struct {
 	Start    Time
 	Duration Time
 
-	fi      *FileInfo
-	file    io.Reader
-	args    []string
-	options []InputOption
+	ctx      context.Context
+	binaries *Binaries
+	filename string
+	describe bool
+	hint     Time
+	extra    []string
+	fi       *FileInfo
+	file     io.Reader
+	args     []string
+	options  []InputOption
 }
 
 func (in *input) input() *input {
 	return in
 }
 
+func (in *input) context() context.Context {
+	if in.ctx == nil {
+		return context.Background()
+	}
+	return in.ctx
+}
+
+// duration returns the length of the input that will be processed once the start
+// position and duration have been applied.  Zero is returned if the length cannot
+// be determined
+func (in *input) duration() Time {
+	length := in.hint
+	if in.fi != nil {
+		length = in.fi.Format.Duration
+	}
+
+	if length > 0 {
+		length -= in.Start
+		if length < 0 {
+			length = 0
+		}
+	}
+
+	if in.Duration > 0 && (length == 0 || in.Duration < length) {
+		length = in.Duration
+	}
+	return length
+}
+
 func (in *input) process(job *transcodeJob) (err error) {
 	if len(in.args) == 0 {
+		in.ctx = job.ctx
+		in.binaries = job.binaries
 		for _, option := range in.options {
 			err = option(in)
 			if err != nil {
@@ -53,30 +91,86 @@ func (in *input) process(job *transcodeJob) (err error) {
 				in.args = append(in.args, "-t", in.Duration.String())
 			}
 
+			in.args = append(in.args, in.extra...)
+
 			if in.URL != nil {
+				if in.URL.Scheme != "" {
+					job.require("input protocol", in.URL.Scheme)
+				}
 				in.args = append(in.args, "-i", in.URL.String())
 			} else if in.fi != nil {
 				in.args = append(in.args, "-i", in.fi.Format.Filename)
 			} else if in.file != nil {
-				job.proc.Stdin(in.file)
 				in.args = append(in.args, "-i", "-")
 			}
 		}
 	}
+
+	// the args are cached but every process needs its STDIN wired up
+	if err == nil && in.stdin() {
+		job.proc.Stdin(in.file)
+	}
 	job.proc.AppendArgs(in.args...)
 	return
 }
 
+// stdin indicates the input is sent to ffmpeg using STDIN and can therefore
+// only be read once
+func (in *input) stdin() bool {
+	return in.URL == nil && in.fi == nil && in.file != nil
+}
+
+// clone returns a copy of the input, with the additional options, that has not
+// been processed yet
+func (in *input) clone(options ...InputOption) *input {
+	clone := *in
+	clone.args = nil
+	clone.extra = nil
+	clone.options = append(append([]InputOption{}, in.options...), options...)
+	return &clone
+}
+
+// spec describes the input as an InputSpec, without probing it.  Inputs read from
+// STDIN cannot be described
+func (in *input) spec() (InputSpec, error) {
+	scratch := &input{describe: true}
+	for _, option := range in.options {
+		if err := option(scratch); err != nil {
+			return InputSpec{}, fmt.Errorf("%w: %v", ErrNoSpec, err)
+		}
+	}
+
+	if scratch.file != nil {
+		return InputSpec{}, fmt.Errorf("%w: input is read from STDIN", ErrNoSpec)
+	}
+
+	is := InputSpec{
+		Filename: scratch.filename,
+		Start:    scratch.Start,
+		Duration: scratch.Duration,
+		Args:     scratch.extra,
+	}
+
+	if scratch.URL != nil {
+		is.URL = scratch.URL.String()
+	}
+	return is, nil
+}
+
 // Input creates a TranscoderInput and applies the options
 func Input(options ...InputOption) TranscoderInput {
 	return &input{options: options}
 }
 
 // InputFilename creates an InputOption that will pass the filename on
-// to the ffmpeg process
+// to the ffmpeg process.  The file is probed with the same context and
+// Binaries that the Transcoder uses
 func InputFilename(filename string) InputOption {
 	return func(input *input) (err error) {
-		input.fi, err = Stat(filename)
+		input.filename = filename
+		if !input.describe {
+			input.fi, err = input.binaries.Stat(input.context(), filename)
+		}
 		return err
 	}
 }
@@ -94,8 +188,10 @@ func InputURL(url *url.URL) InputOption {
 // and sends the data to the ffmpeg process using STDIN
 func InputFile(file *os.File) InputOption {
 	return func(input *input) (err error) {
-		input.fi, err = Stat(file.Name())
 		input.file = file
+		if !input.describe {
+			input.fi, err = input.binaries.Stat(input.context(), file.Name())
+		}
 		return err
 	}
 }
@@ -142,3 +238,22 @@ func DurationOption(duration Time) InputOption {
 		return nil
 	}
 }
+
+// DurationHintOption tells the transcoder how long the input is when the input
+// cannot be probed (such as when using InputReader).  Nothing is passed to ffmpeg,
+// the hint is only used to compute TranscodeInfo.Percent and the ETA values
+func DurationHintOption(duration Time) InputOption {
+	return func(input *input) error {
+		input.hint = duration
+		return nil
+	}
+}
+
+// InputArgsOption passes additional arguments to ffmpeg that apply to this input.  The
+// arguments are placed before the -i option (for instance "-fflags", "+genpts")
+func InputArgsOption(args ...string) InputOption {
+	return func(input *input) error {
+		input.extra = append(input.extra, args...)
+		return nil
+	}
+}
@@ -66,3 +66,27 @@ func TestInputFilename(t *testing.T) {
 		t.Errorf("Expected InputFilename to set fi")
 	}
 }
+
+func TestInputDuration(t *testing.T) {
+	fi := &FileInfo{Format: FormatInfo{Duration: 1 * Hour}}
+	tests := []struct {
+		name  string
+		input *input
+		want  Time
+	}{
+		{"unknown", &input{}, 0},
+		{"file", &input{fi: fi}, 1 * Hour},
+		{"start", &input{fi: fi, Start: 10 * Minute}, 50 * Minute},
+		{"start past end", &input{fi: fi, Start: 2 * Hour}, 0},
+		{"duration", &input{fi: fi, Start: 10 * Minute, Duration: 5 * Minute}, 5 * Minute},
+		{"duration past end", &input{fi: fi, Start: 50 * Minute, Duration: 20 * Minute}, 10 * Minute},
+		{"reader duration", &input{Duration: 5 * Minute}, 5 * Minute},
+		{"reader hint", &input{hint: 1 * Hour, Start: 30 * Minute}, 30 * Minute},
+	}
+
+	for _, test := range tests {
+		if got := test.input.duration(); got != test.want {
+			t.Errorf("%s: want %v got %v", test.name, test.want, got)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package ffmpeg
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -133,6 +134,9 @@ func (ii InterlaceInfo) Type() (t InterlaceType, err error) {
 // InterlaceTranscoder will set up the underlying ffmpeg command to process files with the idet filter (for
 // detecting interlacing) or the bwdif filter (for deinterlacing)
 type InterlaceTranscoder struct {
+	// Binaries selects the ffmpeg and ffprobe executables, if nil then the package
+	// Ffmpeg and Ffprobe commands are used
+	Binaries *Binaries
 }
 
 // NewInterlaceTranscoder returns a transcoder that is ready for detection and deinterlacing
@@ -140,12 +144,13 @@ func NewInterlaceTranscoder() *InterlaceTranscoder {
 	return &InterlaceTranscoder{}
 }
 
-func (it *InterlaceTranscoder) transcode(input TranscoderInput, options ...TranscoderOption) (info InterlaceInfo, err error) {
+func (it *InterlaceTranscoder) transcode(ctx context.Context, input TranscoderInput, options ...TranscoderOption) (info InterlaceInfo, err error) {
 	r, writer := io.Pipe()
 	reader := bufio.NewReader(r)
 	transcoder := NewTranscoder()
+	transcoder.Binaries = it.Binaries
 	options = append([]TranscoderOption{input, StderrOption(writer)}, options...)
-	job, err := transcoder.Transcode(append(options, DiscardOption())...)
+	job, err := transcoder.TranscodeContext(ctx, append(options, DiscardOption())...)
 	if err == nil {
 		for line, _, err := reader.ReadLine(); err == nil; line, _, err = reader.ReadLine() {
 			if index := bytes.Index(line, []byte("Repeated Fields:")); index >= 0 {
@@ -164,6 +169,7 @@ func (it *InterlaceTranscoder) transcode(input TranscoderInput, options ...Trans
 // Deinterlace takes the provided input, applies a deinterlacing filter and writes to the provided output
 func (it *InterlaceTranscoder) Deinterlace(t InterlaceType, input TranscoderInput, output TranscoderOutput, options ...TranscoderOption) (TranscodeJob, error) {
 	transcoder := NewTranscoder()
+	transcoder.Binaries = it.Binaries
 	filter := "mode=1"
 	if t == InterlacedTff {
 		filter = fmt.Sprintf("%s:parity=0", filter)
@@ -179,8 +185,14 @@ func (it *InterlaceTranscoder) Deinterlace(t InterlaceType, input TranscoderInpu
 // Detect will attempt to process the TranscoderInput and determine if it is interlaced or not.  The
 // transcoder will seek to a point 35% into the stream and process at most 35 seconds of video
 func (it *InterlaceTranscoder) Detect(input TranscoderInput, options ...TranscoderOption) (t InterlaceType, err error) {
+	return it.DetectContext(context.Background(), input, options...)
+}
+
+// DetectContext is like Detect but the ffmpeg process is killed if the context is
+// done before detection completes
+func (it *InterlaceTranscoder) DetectContext(ctx context.Context, input TranscoderInput, options ...TranscoderOption) (t InterlaceType, err error) {
 	input.input().options = append(input.input().options, StartPercentOption(35), DurationOption(35*Second))
-	info, err := it.transcode(input, append([]TranscoderOption{VideoFilterOption("idet")}, options...)...)
+	info, err := it.transcode(ctx, input, append([]TranscoderOption{VideoFilterOption("idet")}, options...)...)
 	if err == nil {
 		t, err = info.Type()
 	}
@@ -1,6 +1,7 @@
 package ffmpeg
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -199,7 +200,7 @@ func TestInterlaceTranscode(t *testing.T) {
 			c := &cmd.TestCmd{Stderr: inputTxt}
 			Ffmpeg = c
 			it := NewInterlaceTranscoder()
-			got, err := it.transcode(Input())
+			got, err := it.transcode(context.Background(), Input())
 
 			if err == nil {
 				if want != got {
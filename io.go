@@ -11,8 +11,8 @@ import (
 var (
 	progPtrn       = regexp.MustCompile(`^([^=]+)=\s*([^\s]+)$`)
 	statsPtrn      = regexp.MustCompile(`^frame=\s*[^\s]+\s+fps=\s*[^\s]+\s+q=\s*[^\s]+\s+L?size=\s*[^\s]+\s+time=\s*[^\s]+\s+bitrate=\s*[^\s]+\s+speed=\s*[^\s]+$`)
-	finalStatsPtrn = regexp.MustCompile(`^video:[^\s]+\s+audio:[^\s]+\s+subtitle:[^\s]+\s+other\s+streams:[^\s]+\s+global\s+headers:[^\s]+\s+muxing\s+overhead:\s+[^\s]+$`)
-	repeatPtrn     = regexp.MustCompile(`^Last message repeated`)
+	finalStatsPtrn = regexp.MustCompile(`^(\[[^\]]+\]\s+)?video:[^\s]+\s+audio:[^\s]+\s+subtitle:[^\s]+\s+other\s+streams:[^\s]+\s+global\s+headers:[^\s]+\s+muxing\s+overhead:\s+[^\s]+$`)
+	repeatPtrn     = regexp.MustCompile(`^(\[[^\]]+\]\s+)?Last message repeated`)
 )
 
 type filterReader struct {
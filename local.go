@@ -0,0 +1,127 @@
+package ffmpeg
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/mh-orange/cmd"
+)
+
+// errNotStarted is returned when waiting for, killing or signaling a process that
+// has not been started
+var errNotStarted = errors.New("process not started")
+
+// localCommand is the cmd.Command of the LocalExecutor
+type localCommand struct {
+	path string
+	args []string
+}
+
+func (lc *localCommand) Path() string        { return lc.path }
+func (lc *localCommand) SetPath(path string) { lc.path = path }
+
+func (lc *localCommand) Process() cmd.Process {
+	return &localProcess{args: append([]string{lc.path}, lc.args...)}
+}
+
+// localProcess runs a program on this host with os/exec.  The program is looked up
+// in the PATH when the process is started, so a missing executable is reported by
+// Start (as an error wrapping exec.ErrNotFound).  Writers given to Stdout and Stderr
+// are closed once the program exits, so that readers of pipes see the end of the
+// output
+type localProcess struct {
+	args   []string
+	stdin  io.Reader
+	stdout []io.Writer
+	stderr []io.Writer
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	doneCh chan struct{}
+	err    error
+}
+
+func (lp *localProcess) AppendArgs(args ...string) { lp.args = append(lp.args, args...) }
+func (lp *localProcess) Args() []string            { return lp.args }
+func (lp *localProcess) Stdin(reader io.Reader)    { lp.stdin = reader }
+func (lp *localProcess) Stdout(writer io.Writer)   { lp.stdout = append(lp.stdout, writer) }
+func (lp *localProcess) Stderr(writer io.Writer)   { lp.stderr = append(lp.stderr, writer) }
+
+func (lp *localProcess) Start() error {
+	path, err := exec.LookPath(lp.args[0])
+	if err != nil {
+		return err
+	}
+
+	c := exec.Command(path, lp.args[1:]...)
+	c.Stdin = lp.stdin
+	if len(lp.stdout) > 0 {
+		c.Stdout = io.MultiWriter(lp.stdout...)
+	}
+
+	if len(lp.stderr) > 0 {
+		c.Stderr = io.MultiWriter(lp.stderr...)
+	}
+
+	if err := c.Start(); err != nil {
+		return err
+	}
+
+	lp.mu.Lock()
+	lp.cmd = c
+	lp.doneCh = make(chan struct{})
+	lp.mu.Unlock()
+
+	go func() {
+		err := c.Wait()
+		for _, writer := range append(lp.stdout, lp.stderr...) {
+			if closer, ok := writer.(io.Closer); ok && writer != os.Stdout && writer != os.Stderr {
+				closer.Close()
+			}
+		}
+
+		lp.mu.Lock()
+		lp.err = err
+		lp.mu.Unlock()
+		close(lp.doneCh)
+	}()
+	return nil
+}
+
+func (lp *localProcess) started() *exec.Cmd {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return lp.cmd
+}
+
+func (lp *localProcess) Wait() error {
+	if lp.started() == nil {
+		return errNotStarted
+	}
+
+	<-lp.doneCh
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return lp.err
+}
+
+func (lp *localProcess) Kill() error {
+	c := lp.started()
+	if c == nil {
+		return errNotStarted
+	}
+	return c.Process.Kill()
+}
+
+// Signal sends the signal to the program.  This is what allows local transcode jobs
+// to be paused, resumed and stopped gracefully
+func (lp *localProcess) Signal(sig os.Signal) error {
+	c := lp.started()
+	if c == nil {
+		return errNotStarted
+	}
+	return c.Process.Signal(sig)
+}
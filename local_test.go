@@ -0,0 +1,168 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// helperEnv makes the test binary act as ffmpeg (see helperFfmpeg) so that the
+// LocalExecutor can be tested with real processes
+const helperEnv = "FFMPEG_TEST_HELPER"
+
+func init() {
+	if mode := os.Getenv(helperEnv); mode != "" {
+		os.Exit(helperFfmpeg(mode))
+	}
+}
+
+// helperFfmpeg writes a progress block to stderr.  In "exit" mode it then exits
+// successfully, in "fail" mode it fails with a full muxing queue, otherwise it waits
+// for an interrupt, like ffmpeg does
+func helperFfmpeg(mode string) int {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	fmt.Fprint(os.Stderr, "frame=1\nout_time_us=1000000\nprogress=continue\n")
+	if mode == "exit" {
+		return 0
+	} else if mode == "fail" {
+		fmt.Fprintln(os.Stderr, "Too many packets buffered for output stream 0:1.")
+		return 1
+	}
+
+	select {
+	case <-signals:
+		fmt.Fprintln(os.Stderr, "Exiting normally, received signal 2.")
+		return 255
+	case <-time.After(30 * time.Second):
+		return 1
+	}
+}
+
+// helperBinaries returns Binaries whose ffmpeg is the test binary, found in the PATH
+func helperBinaries(t *testing.T, mode string) *Binaries {
+	if runtime.GOOS == "windows" {
+		t.Skip("the helper process is not supported on windows")
+	}
+
+	dir := t.TempDir()
+	executable, err := os.Executable()
+	if err == nil {
+		err = os.Symlink(executable, filepath.Join(dir, "ffmpeg"))
+	}
+
+	if err != nil {
+		t.Skipf("cannot link the test binary: %v", err)
+	}
+
+	t.Setenv("PATH", dir)
+	t.Setenv(helperEnv, mode)
+	return NewBinaries("ffmpeg", "ffprobe")
+}
+
+func TestLocalExecutorLookPath(t *testing.T) {
+	binaries := helperBinaries(t, "exit")
+	transcoder := NewTranscoder()
+	transcoder.Binaries = binaries
+	job, err := transcoder.Transcode()
+	if err == nil {
+		err = job.Wait()
+	}
+
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if got := job.Result().Frames; got != 1 {
+		t.Errorf("wanted 1 frame got %d", got)
+	}
+
+	if _, err := binaries.Stat(context.Background(), "input.mkv"); !errors.Is(err, ErrFfprobeNotFound) {
+		t.Errorf("wanted %v got %v", ErrFfprobeNotFound, err)
+	}
+
+	if _, err := binaries.KeyFrames(context.Background(), "input.mkv", 0); !errors.Is(err, ErrFfprobeNotFound) {
+		t.Errorf("wanted %v got %v", ErrFfprobeNotFound, err)
+	}
+}
+
+func TestLocalExecutorNotFound(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	transcoder := NewTranscoder()
+	transcoder.Binaries = NewExecutorBinaries(LocalExecutor{})
+	if _, err := transcoder.Transcode(); !errors.Is(err, ErrFfmpegNotFound) {
+		t.Errorf("wanted %v got %v", ErrFfmpegNotFound, err)
+	}
+
+	if _, err := transcoder.Binaries.AnalyzeGOP(context.Background(), "input.mkv", 0); !errors.Is(err, ErrFfprobeNotFound) {
+		t.Errorf("wanted %v got %v", ErrFfprobeNotFound, err)
+	}
+
+	proc := LocalExecutor{}.Command("ffmpeg").Process()
+	if err := proc.Wait(); err != errNotStarted {
+		t.Errorf("wanted %v got %v", errNotStarted, err)
+	}
+
+	if err := proc.Kill(); err != errNotStarted {
+		t.Errorf("wanted %v got %v", errNotStarted, err)
+	}
+}
+
+func TestLocalExecutorStop(t *testing.T) {
+	transcoder := NewTranscoder()
+	transcoder.Binaries = helperBinaries(t, "wait")
+	job, err := transcoder.Transcode()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	<-job.Progress()
+	job.Stop(10 * time.Second)
+	if err := job.Wait(); err != ErrStopped {
+		t.Errorf("wanted %v got %v", ErrStopped, err)
+	}
+}
+
+func TestLocalExecutorPause(t *testing.T) {
+	if sigStop == nil {
+		t.Skip("pausing is not supported on this platform")
+	}
+
+	transcoder := NewTranscoder()
+	transcoder.Binaries = helperBinaries(t, "wait")
+	job, err := transcoder.Transcode()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	updates, _ := job.Subscribe()
+	<-updates
+	for _, want := range []bool{true, false} {
+		if want {
+			err = job.Pause()
+		} else {
+			err = job.Resume()
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		if info := <-updates; info.Paused != want {
+			t.Errorf("wanted paused %v got %v", want, info.Paused)
+		}
+	}
+
+	// a paused process is continued so that it can handle the interrupt
+	job.Pause()
+	job.Stop(10 * time.Second)
+	if err := job.Wait(); err != ErrStopped {
+		t.Errorf("wanted %v got %v", ErrStopped, err)
+	}
+}
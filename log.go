@@ -0,0 +1,171 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLogSize is the number of log lines kept by a transcode job unless
+// LogSizeOption is given
+const DefaultLogSize = 1000
+
+// logHeadLines is the number of lines at the start of the log that are kept
+// even after the log has wrapped.  ffmpeg reports problems opening the inputs
+// and outputs at the start of the log, and the reason it exited at the end
+const logHeadLines = 20
+
+var (
+	componentPtrn = regexp.MustCompile(`^\[([^\]]+?) @ (0x[0-9a-fA-F]+)\]\s*`)
+	levelPtrn     = regexp.MustCompile(`^\[(trace|debug|verbose|info|warning|error|fatal|panic)\]\s*`)
+)
+
+// LogEntry is a single line of ffmpeg log output
+type LogEntry struct {
+	// Time is when the line was read
+	Time time.Time
+
+	// Position is the output position (the most recent progress Time) when the
+	// line was read
+	Position Time
+
+	// Level is the severity of the message.  ffmpeg only prints the severity when the
+	// log level is prefixed with "level+" (for instance "-loglevel level+info"), lines
+	// without a severity are LogInfo
+	Level LogLevel
+
+	// Component is the name of the ffmpeg component that logged the message, such as
+	// "h264" for a line starting with "[h264 @ 0x55d0c0a1e2c0]".  Component is empty
+	// for messages logged by ffmpeg itself
+	Component string
+
+	// Message is the text of the line without the component and severity
+	Message string
+
+	// Text is the complete line
+	Text string
+}
+
+func parseLogEntry(t time.Time, line string) LogEntry {
+	entry := LogEntry{Time: t, Level: LogInfo, Text: line}
+	if match := componentPtrn.FindStringSubmatch(line); match != nil {
+		entry.Component = match[1]
+		line = line[len(match[0]):]
+	}
+
+	if match := levelPtrn.FindStringSubmatch(line); match != nil {
+		entry.Level, _ = LogLevelString(match[1])
+		line = line[len(match[0]):]
+	}
+	entry.Message = line
+	return entry
+}
+
+// LogIterator steps through the entries of a job log
+type LogIterator struct {
+	entries []LogEntry
+	entry   LogEntry
+	omitted int
+}
+
+// Scan advances to the next entry, returning false when there are no more entries
+func (li *LogIterator) Scan() bool {
+	if len(li.entries) == 0 {
+		return false
+	}
+	li.entry, li.entries = li.entries[0], li.entries[1:]
+	return true
+}
+
+// Entry returns the current entry
+func (li *LogIterator) Entry() LogEntry {
+	return li.entry
+}
+
+// Omitted returns the number of entries that were discarded from the middle of
+// the log because it grew larger than its size
+func (li *LogIterator) Omitted() int {
+	return li.omitted
+}
+
+// jobLog keeps the first lines of the log and a ring buffer of the most recent
+// lines, so that the log never grows larger than its size
+type jobLog struct {
+	mu      sync.Mutex
+	size    int
+	head    []LogEntry
+	tail    []LogEntry
+	next    int
+	omitted int
+}
+
+func newJobLog(size int) *jobLog {
+	if size < 2 {
+		size = 2
+	}
+	return &jobLog{size: size}
+}
+
+func (jl *jobLog) add(entry LogEntry) {
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+
+	headSize := logHeadLines
+	if headSize > jl.size/2 {
+		headSize = jl.size / 2
+	}
+
+	if len(jl.head) < headSize {
+		jl.head = append(jl.head, entry)
+	} else if len(jl.tail) < jl.size-headSize {
+		jl.tail = append(jl.tail, entry)
+	} else {
+		jl.tail[jl.next] = entry
+		jl.next = (jl.next + 1) % len(jl.tail)
+		jl.omitted++
+	}
+}
+
+// ordered returns a copy of the kept entries in the order they were added, the
+// caller must hold the lock
+func (jl *jobLog) ordered() []LogEntry {
+	entries := make([]LogEntry, 0, len(jl.head)+len(jl.tail))
+	entries = append(entries, jl.head...)
+	entries = append(entries, jl.tail[jl.next:]...)
+	return append(entries, jl.tail[:jl.next]...)
+}
+
+func (jl *jobLog) iterator() *LogIterator {
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+	return &LogIterator{entries: jl.ordered(), omitted: jl.omitted}
+}
+
+// lines returns the text of the kept entries
+func (jl *jobLog) lines() []string {
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+
+	lines := make([]string, 0, len(jl.head)+len(jl.tail))
+	for _, entry := range jl.ordered() {
+		lines = append(lines, entry.Text)
+	}
+	return lines
+}
+
+// String joins the kept lines, noting where lines were omitted
+func (jl *jobLog) String() string {
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+
+	lines := make([]string, 0, len(jl.head)+len(jl.tail)+1)
+	for i, entry := range jl.ordered() {
+		if jl.omitted > 0 && i == len(jl.head) {
+			lines = append(lines, fmt.Sprintf("... %d lines omitted ...", jl.omitted))
+		}
+		lines = append(lines, entry.Text)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,82 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLogEntry(t *testing.T) {
+	tests := []struct {
+		input string
+		want  LogEntry
+	}{
+		{"Input #0, mpegts, from 'foo.ts':", LogEntry{Level: LogInfo, Message: "Input #0, mpegts, from 'foo.ts':"}},
+		{"[h264 @ 0x55d0c0a1e2c0] mmco: unref short failure", LogEntry{Level: LogInfo, Component: "h264", Message: "mmco: unref short failure"}},
+		{"[mp4 @ 0x7f8b1c004a00] [warning] Non-monotonous DTS in output stream 0:1", LogEntry{Level: LogWarning, Component: "mp4", Message: "Non-monotonous DTS in output stream 0:1"}},
+		{"[out#0/mp4 @ 0x5600] [error] Error opening output", LogEntry{Level: LogError, Component: "out#0/mp4", Message: "Error opening output"}},
+		{"[fatal] foo.mkv: No such file or directory", LogEntry{Level: LogFatal, Message: "foo.mkv: No such file or directory"}},
+		{"[Parsed_bwdif_0] not a component", LogEntry{Level: LogInfo, Message: "[Parsed_bwdif_0] not a component"}},
+	}
+
+	now := time.Now()
+	for i, test := range tests {
+		test.want.Time = now
+		test.want.Text = test.input
+		got := parseLogEntry(now, test.input)
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("tests[%d] want %+v got %+v", i, test.want, got)
+		}
+	}
+}
+
+func TestJobLog(t *testing.T) {
+	tests := []struct {
+		size        int
+		lines       int
+		wantLines   []string
+		wantOmitted int
+	}{
+		{6, 4, []string{"1", "2", "3", "4"}, 0},
+		{6, 6, []string{"1", "2", "3", "4", "5", "6"}, 0},
+		{6, 10, []string{"1", "2", "3", "8", "9", "10"}, 4},
+		{100, 100, nil, 0},
+		{100, 101, nil, 1},
+	}
+
+	for i, test := range tests {
+		log := newJobLog(test.size)
+		for j := 1; j <= test.lines; j++ {
+			log.add(parseLogEntry(time.Now(), fmt.Sprint(j)))
+		}
+
+		iterator := log.iterator()
+		var got []string
+		for iterator.Scan() {
+			got = append(got, iterator.Entry().Text)
+		}
+
+		if len(got) != test.lines-test.wantOmitted {
+			t.Errorf("tests[%d] want %d lines got %d", i, test.lines-test.wantOmitted, len(got))
+		} else if test.wantLines != nil && !reflect.DeepEqual(test.wantLines, got) {
+			t.Errorf("tests[%d] want %v got %v", i, test.wantLines, got)
+		}
+
+		if iterator.Omitted() != test.wantOmitted {
+			t.Errorf("tests[%d] want %d omitted got %d", i, test.wantOmitted, iterator.Omitted())
+		}
+
+		if !reflect.DeepEqual(got, log.lines()) {
+			t.Errorf("tests[%d] want lines %v got %v", i, got, log.lines())
+		}
+
+		marker := fmt.Sprintf("... %d lines omitted ...", test.wantOmitted)
+		if test.wantOmitted > 0 && !strings.Contains(log.String(), marker) {
+			t.Errorf("tests[%d] expected %q in %q", i, marker, log.String())
+		} else if test.wantOmitted == 0 && log.String() != strings.Join(got, "\n") {
+			t.Errorf("tests[%d] want %q got %q", i, strings.Join(got, "\n"), log.String())
+		}
+	}
+}
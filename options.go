@@ -3,6 +3,7 @@ package ffmpeg
 import (
 	"fmt"
 	"io"
+	"time"
 )
 
 // TranscoderOption is passed to the Transcode function to set things like
@@ -18,14 +19,28 @@ func (tof transcoderOptionFunc) process(job *transcodeJob) error { return tof(jo
 // StderrOption will tee the stderr output from the underlying ffmpeg process and
 // write the output to the given writer
 func StderrOption(writer io.WriteCloser) TranscoderOption {
-	return transcoderOptionFunc(func(job *transcodeJob) error {
-		job.proc.Stderr(writer)
-		return nil
-	})
+	return stderrOption{writer}
+}
+
+// stderrOption is a named type so that RetryTranscoder can find the writer, which
+// is closed when ffmpeg exits
+type stderrOption struct {
+	writer io.WriteCloser
+}
+
+func (so stderrOption) process(job *transcodeJob) error {
+	job.proc.Stderr(so.writer)
+	return nil
 }
 
 func LogOption(writer io.Writer) TranscoderOption {
 	return transcoderOptionFunc(func(job *transcodeJob) error {
+		// a plan records the writer itself, nothing would ever read the pipe
+		if job.planning() {
+			job.proc.Stderr(writer)
+			return nil
+		}
+
 		pr, pw := io.Pipe()
 		job.proc.Stderr(pw)
 		reader := newFilterReader(pr, progPtrn, repeatPtrn)
@@ -47,14 +62,65 @@ func LogOption(writer io.Writer) TranscoderOption {
 	})
 }
 
-// VideoFilterOption sets a video filter chain on a transcoder
-func VideoFilterOption(chaindef string) TranscoderOption {
+// WatchdogOption kills the ffmpeg process if the output time does not advance
+// within the window.  This catches network inputs and damaged files that leave
+// ffmpeg running without making progress.  The job fails with a *TranscodeError
+// caused by ErrStalled.  Time spent paused is not counted
+func WatchdogOption(window time.Duration) TranscoderOption {
 	return transcoderOptionFunc(func(job *transcodeJob) error {
-		job.proc.AppendArgs("-lavfi", chaindef)
+		job.stallWindow = window
 		return nil
 	})
 }
 
+// CapabilityCheckOption makes the transcoder check that ffmpeg supports the encoders,
+// filters, formats and protocols the job uses before starting it.  If something is
+// not supported then an *UnsupportedError is returned by Transcode.  The ffmpeg
+// capabilities are loaded (see LoadCapabilities) the first time they are needed
+func CapabilityCheckOption() TranscoderOption {
+	return transcoderOptionFunc(func(job *transcodeJob) error {
+		job.checkCapabilities = true
+		return nil
+	})
+}
+
+// LogSizeOption sets the number of log lines kept by the job, the default is
+// DefaultLogSize.  Once the log is full the oldest lines are discarded, except
+// for those at the very start of the log
+func LogSizeOption(size int) TranscoderOption {
+	return transcoderOptionFunc(func(job *transcodeJob) error {
+		job.log = newJobLog(size)
+		return nil
+	})
+}
+
+// LogHandlerOption calls handler with every log line as ffmpeg writes it.  The
+// handler is called from the goroutine reading the ffmpeg output, so a handler that
+// blocks holds up the transcode
+func LogHandlerOption(handler func(LogEntry)) TranscoderOption {
+	return transcoderOptionFunc(func(job *transcodeJob) error {
+		job.logHandlers = append(job.logHandlers, handler)
+		return nil
+	})
+}
+
+// VideoFilterOption sets a video filter chain on a transcoder
+func VideoFilterOption(chaindef string) TranscoderOption {
+	return videoFilterOption(chaindef)
+}
+
+// videoFilterOption is a named type, rather than a transcoderOptionFunc, so that it
+// can be described by a JobSpec
+type videoFilterOption string
+
+func (vfo videoFilterOption) process(job *transcodeJob) error {
+	for _, name := range filterNames(string(vfo)) {
+		job.require("filter", name)
+	}
+	job.proc.AppendArgs("-lavfi", string(vfo))
+	return nil
+}
+
 // DiscardOption sets the transcoder output format to null and discards the output
 func DiscardOption() TranscoderOption {
 	return transcoderOptionFunc(func(job *transcodeJob) error {
@@ -64,10 +130,14 @@ func DiscardOption() TranscoderOption {
 }
 
 func MapOption(index int) TranscoderOption {
-	return transcoderOptionFunc(func(job *transcodeJob) error {
-		job.proc.AppendArgs("-map", fmt.Sprintf("%d", index))
-		return nil
-	})
+	return mapSpecifierOption(fmt.Sprintf("%d", index))
+}
+
+// MapProgramOption maps all the streams belonging to the program (identified by its
+// program number) from the input at the given index.  This is useful for selecting a
+// single service out of a multi-program transport stream
+func MapProgramOption(index int, program int) TranscoderOption {
+	return mapSpecifierOption(fmt.Sprintf("%d:p:%d", index, program))
 }
 
 func MapMetadataOption(index int) TranscoderOption {
@@ -1,6 +1,7 @@
 package ffmpeg
 
 import (
+	"fmt"
 	"io"
 )
 
@@ -30,6 +31,8 @@ type output struct {
 	format        string
 	formatOptions []string
 
+	extra []string
+
 	options []OutputOption
 }
 
@@ -37,11 +40,36 @@ func (out *output) output() *output {
 	return out
 }
 
+// clone returns a copy of the output with the additional options
+func (out *output) clone(options ...OutputOption) *output {
+	clone := *out
+	clone.extra = nil
+	clone.options = append(append([]OutputOption{}, out.options...), options...)
+	return &clone
+}
+
 func (out *output) process(job *transcodeJob) error {
+	// the options are applied every time the output is processed, so anything that
+	// options append to must start out empty
+	out.extra = nil
 	for _, option := range out.options {
 		option(out)
 	}
 
+	for _, codec := range []string{out.vCodec, out.aCodec, out.sCodec} {
+		if codec != "" && codec != "copy" {
+			job.require("encoder", codec)
+		}
+	}
+
+	if out.format != "" {
+		job.require("muxer", out.format)
+	}
+
+	if out.pix_fmt != "" {
+		job.require("pixel format", out.pix_fmt)
+	}
+
 	if out.vCodec != "" {
 		job.proc.AppendArgs("-c:v", out.vCodec)
 		job.proc.AppendArgs(out.vCodecOptions...)
@@ -65,6 +93,8 @@ func (out *output) process(job *transcodeJob) error {
 		job.proc.AppendArgs(out.formatOptions...)
 	}
 
+	job.proc.AppendArgs(out.extra...)
+
 	if out.filename != "" {
 		job.proc.AppendArgs("-y", out.filename)
 	} else if out.writer != nil {
@@ -74,6 +104,32 @@ func (out *output) process(job *transcodeJob) error {
 	return nil
 }
 
+// spec describes the output as an OutputSpec.  Outputs written to STDOUT cannot
+// be described
+func (out *output) spec() (OutputSpec, error) {
+	scratch := out.clone()
+	for _, option := range scratch.options {
+		option(scratch)
+	}
+
+	if scratch.writer != nil {
+		return OutputSpec{}, fmt.Errorf("%w: output is written to STDOUT", ErrNoSpec)
+	}
+
+	return OutputSpec{
+		Filename:          scratch.filename,
+		Format:            scratch.format,
+		FormatOptions:     scratch.formatOptions,
+		VideoCodec:        scratch.vCodec,
+		VideoCodecOptions: scratch.vCodecOptions,
+		PixFmt:            scratch.pix_fmt,
+		AudioCodec:        scratch.aCodec,
+		AudioCodecOptions: scratch.aCodecOptions,
+		SubtitleCodec:     scratch.sCodec,
+		Args:              scratch.extra,
+	}, nil
+}
+
 // Output returns a TranscoderOutput with the given output options
 func Output(options ...OutputOption) TranscoderOutput {
 	return &output{options: options}
@@ -161,3 +217,12 @@ func OutputFormat(format string) OutputOption {
 		return nil
 	}
 }
+
+// OutputArgsOption passes additional arguments to ffmpeg that apply to this output.  The
+// arguments are placed immediately before the output filename
+func OutputArgsOption(args ...string) OutputOption {
+	return func(output *output) error {
+		output.extra = append(output.extra, args...)
+		return nil
+	}
+}
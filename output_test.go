@@ -36,3 +36,15 @@ func TestOutputOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestOutputProcessTwice(t *testing.T) {
+	out := Output(OutputFilename("test.mp4"), OutputArgsOption("-movflags", "+faststart")).output()
+	want := []string{"", "-movflags", "+faststart", "-y", "test.mp4"}
+	for i := 0; i < 2; i++ {
+		proc := (&cmd.TestCmd{}).Process()
+		out.process(&transcodeJob{proc: proc})
+		if got := proc.Args(); !reflect.DeepEqual(want, got) {
+			t.Errorf("process %d want %q got %q", i+1, want, got)
+		}
+	}
+}
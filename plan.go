@@ -0,0 +1,141 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/mh-orange/cmd"
+)
+
+// ErrPlanOnly is returned if something attempts to start the process of a Plan
+var ErrPlanOnly = errors.New("planned process cannot be started")
+
+var shellSafePtrn = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Plan is the ffmpeg command that a Transcoder would run for a set of options.  It
+// is useful for showing operators the exact command, keeping it for auditing and for
+// testing how options combine
+type Plan struct {
+	// Args is the complete argument vector, starting with the ffmpeg executable
+	Args []string `json:"args"`
+
+	// Stdin describes what is sent to ffmpeg's standard input (such as the file name
+	// of an InputReader that is an *os.File, or the type of the reader).  Stdin is
+	// empty if ffmpeg's standard input is not used
+	Stdin string `json:"stdin,omitempty"`
+
+	// Stdout describes the writers that ffmpeg's standard output is sent to
+	Stdout []string `json:"stdout,omitempty"`
+
+	// Stderr describes the writers, other than the job's own log, that ffmpeg's
+	// standard error is sent to (for instance by StderrOption)
+	Stderr []string `json:"stderr,omitempty"`
+
+	// Duration is the length of the longest input, after StartOption and DurationOption
+	// are applied.  Duration is zero if it is not known
+	Duration Time `json:"duration,omitempty"`
+
+	// Shell is Args rendered as a command that can be pasted into a POSIX shell
+	Shell string `json:"shell"`
+}
+
+// String returns the shell rendering of the plan
+func (plan *Plan) String() string {
+	return plan.Shell
+}
+
+// Plan applies the options, the same way that Transcode does, and returns the command
+// that would be run without starting ffmpeg.  Inputs given with InputFilename are still
+// probed with ffprobe and CapabilityCheckOption is not checked.  Writers that are passed
+// to StderrOption and LogOption are recorded in the plan but are not written to or closed
+func (transcoder *Transcoder) Plan(options ...TranscoderOption) (*Plan, error) {
+	return transcoder.PlanContext(context.Background(), options...)
+}
+
+// PlanContext is like Plan but ffprobe is killed if the context is done while probing
+// inputs
+func (transcoder *Transcoder) PlanContext(ctx context.Context, options ...TranscoderOption) (*Plan, error) {
+	// inputs are planned as clones, so that nothing the plan does (such as probing
+	// and caching the arguments) is kept by the caller's inputs
+	planned := make([]TranscoderOption, len(options))
+	for i, option := range options {
+		if in, ok := option.(*input); ok {
+			option = in.clone()
+		}
+		planned[i] = option
+	}
+
+	proc := &planProcess{Process: transcoder.Binaries.ffmpeg().Process()}
+	job, err := transcoder.prepare(ctx, proc, planned)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Args:     proc.Args(),
+		Stdin:    proc.stdin,
+		Stdout:   proc.stdout,
+		Stderr:   proc.stderr,
+		Duration: job.info.Duration,
+	}
+	plan.Shell = shellJoin(plan.Args)
+	return plan, nil
+}
+
+// planProcess records how the process is wired up and refuses to start
+type planProcess struct {
+	cmd.Process
+	stdin  string
+	stdout []string
+	stderr []string
+}
+
+func (pp *planProcess) Stdin(reader io.Reader) {
+	pp.Process.Stdin(reader)
+	pp.stdin = describe(reader)
+}
+
+func (pp *planProcess) Stdout(writer io.Writer) {
+	pp.Process.Stdout(writer)
+	pp.stdout = append(pp.stdout, describe(writer))
+}
+
+func (pp *planProcess) Stderr(writer io.Writer) {
+	pp.Process.Stderr(writer)
+	pp.stderr = append(pp.stderr, describe(writer))
+}
+
+func (pp *planProcess) Start() error { return ErrPlanOnly }
+
+// planning reports whether the job is being planned rather than run, options that
+// start goroutines or open resources skip doing so when planning
+func (job *transcodeJob) planning() bool {
+	_, ok := job.proc.(*planProcess)
+	return ok
+}
+
+// describe returns the name of a file (or anything else with a name), otherwise
+// the type of v
+func describe(v interface{}) string {
+	if named, ok := v.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// shellJoin quotes the arguments for a POSIX shell and joins them with spaces
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if shellSafePtrn.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,172 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mh-orange/cmd"
+)
+
+func TestTranscoderPlan(t *testing.T) {
+	u, _ := url.Parse("http://example.com/input.mkv")
+	reader := bytes.NewReader(nil)
+	writer := &bytes.Buffer{}
+	stderr := &closeRecorder{}
+
+	tests := []struct {
+		name     string
+		options  []TranscoderOption
+		args     []string
+		stdin    string
+		stdout   []string
+		stderr   []string
+		duration Time
+	}{
+		{
+			name: "files",
+			options: []TranscoderOption{
+				Input(InputURL(u), StartOption(10*Second), DurationOption(30*Second)),
+				VideoFilterOption("drawtext=text='it''s'"),
+				Output(OutputFilename("my output.mp4"), DefaultH264()),
+			},
+			args:     []string{"-ss", "00:00:10.000000", "-t", "00:00:30.000000", "-i", "http://example.com/input.mkv", "-lavfi", "drawtext=text='it''s'", "-c:v", "libx264", "-preset", "medium", "-tune", "film", "-y", "my output.mp4"},
+			duration: 30 * Second,
+		},
+		{
+			name: "pipes",
+			options: []TranscoderOption{
+				Input(InputReader(reader)),
+				Output(OutputWriter(writer), OutputFormat("matroska")),
+				LogOption(&bytes.Buffer{}),
+				StderrOption(stderr),
+			},
+			args:   []string{"-i", "-", "-f", "matroska", "-"},
+			stdin:  "*bytes.Reader",
+			stdout: []string{"*bytes.Buffer"},
+			stderr: []string{"*bytes.Buffer", "*ffmpeg.closeRecorder"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transcoder := NewTranscoder()
+			transcoder.Binaries = NewBinaries("/opt/ffmpeg/ffmpeg", "/opt/ffmpeg/ffprobe")
+			plan, err := transcoder.Plan(test.options...)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			want := append(append([]string{"/opt/ffmpeg/ffmpeg"}, ffmpegArgs...), test.args...)
+			if !reflect.DeepEqual(want, plan.Args) {
+				t.Errorf("wanted args %q got %q", want, plan.Args)
+			}
+
+			if plan.Stdin != test.stdin || !reflect.DeepEqual(test.stdout, plan.Stdout) || !reflect.DeepEqual(test.stderr, plan.Stderr) {
+				t.Errorf("wanted wiring %q %q %q got %q %q %q", test.stdin, test.stdout, test.stderr, plan.Stdin, plan.Stdout, plan.Stderr)
+			}
+
+			if plan.Duration != test.duration {
+				t.Errorf("wanted duration %v got %v", test.duration, plan.Duration)
+			}
+
+			if !strings.HasPrefix(plan.String(), "/opt/ffmpeg/ffmpeg ") {
+				t.Errorf("Unexpected shell rendering %q", plan.String())
+			}
+		})
+	}
+
+	// planning must leave the caller's writers alone
+	if stderr.closed {
+		t.Errorf("StderrOption writer was closed by Plan")
+	}
+}
+
+// closeRecorder is a WriteCloser that records whether it was closed
+type closeRecorder struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (cr *closeRecorder) Close() error {
+	cr.closed = true
+	return nil
+}
+
+// stdinCmd records the reader given to the Stdin of its processes
+type stdinCmd struct {
+	cmd.Command
+	stdin io.Reader
+}
+
+func (sc *stdinCmd) Process() cmd.Process {
+	return &stdinProcess{Process: sc.Command.Process(), cmd: sc}
+}
+
+type stdinProcess struct {
+	cmd.Process
+	cmd *stdinCmd
+}
+
+func (sp *stdinProcess) Stdin(reader io.Reader) {
+	sp.cmd.stdin = reader
+	sp.Process.Stdin(reader)
+}
+
+func TestTranscoderPlanThenTranscode(t *testing.T) {
+	reader := bytes.NewReader(nil)
+	options := []TranscoderOption{Input(InputReader(reader)), Output(OutputFilename("out.mkv"))}
+	sc := &stdinCmd{Command: &cmd.TestCmd{}}
+	transcoder := NewTranscoder()
+	transcoder.Binaries = &Binaries{Ffmpeg: sc}
+
+	for i := 0; i < 2; i++ {
+		plan, err := transcoder.Plan(options...)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if plan.Stdin != "*bytes.Reader" {
+			t.Errorf("plan %d wanted stdin %q got %q", i, "*bytes.Reader", plan.Stdin)
+		}
+	}
+
+	// the second transcode uses the cached input args
+	for i := 0; i < 2; i++ {
+		sc.stdin = nil
+		job, err := transcoder.Transcode(options...)
+		if err == nil {
+			err = job.Wait()
+		}
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if sc.stdin != reader {
+			t.Errorf("transcode %d wanted the input reader on stdin got %v", i, sc.stdin)
+		}
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"ffmpeg", "-i", "input.mkv"}, "ffmpeg -i input.mkv"},
+		{[]string{"ffmpeg", "-i", "my input.mkv"}, "ffmpeg -i 'my input.mkv'"},
+		{[]string{"-vf", "drawtext=text='it''s'"}, `-vf 'drawtext=text='\''it'\'''\''s'\'''`},
+		{[]string{"-metadata", "title=$HOME"}, "-metadata 'title=$HOME'"},
+		{[]string{""}, "''"},
+	}
+
+	for _, test := range tests {
+		if got := shellJoin(test.args); got != test.want {
+			t.Errorf("wanted %s got %s", test.want, got)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mh-orange/cmd"
+)
+
+// FrameInfo is the information ffprobe reports about a single decoded frame
+type FrameInfo struct {
+	// MediaType indicates if the frame is video, audio, etc
+	MediaType MediaType
+
+	// StreamIndex is the index of the stream the frame belongs to
+	StreamIndex int
+
+	// KeyFrame is true when the frame is a key frame
+	KeyFrame bool
+
+	// PTS is the presentation timestamp in stream time base units
+	PTS int64
+
+	// PTSTime is the presentation timestamp
+	PTSTime Time
+
+	// DTS is the decoding timestamp, in stream time base units, of the packet the frame was decoded from
+	DTS int64
+
+	// DTSTime is the decoding timestamp of the packet the frame was decoded from
+	DTSTime Time
+
+	// Duration is the length of the frame
+	Duration Time
+
+	// Size is the size (in bytes) of the packet the frame was decoded from
+	Size int
+
+	// PictType is the picture type of a video frame (I, P, B, etc)
+	PictType string
+
+	// Interlaced is true for interlaced video frames
+	Interlaced bool
+
+	// TopFieldFirst is true when an interlaced frame has the top field first
+	TopFieldFirst bool
+}
+
+func (fi *FrameInfo) parse(values map[string]string) (err error) {
+	for k, v := range values {
+		if v == "N/A" || v == "" {
+			continue
+		}
+
+		switch k {
+		case "media_type":
+			fi.MediaType, err = MediaTypeString(v)
+		case "stream_index":
+			fi.StreamIndex, err = strconv.Atoi(v)
+		case "key_frame":
+			fi.KeyFrame = v == "1"
+		case "pts", "pkt_pts":
+			fi.PTS, err = strconv.ParseInt(v, 10, 64)
+		case "pts_time", "pkt_pts_time":
+			err = fi.PTSTime.Parse(v)
+		case "pkt_dts":
+			fi.DTS, err = strconv.ParseInt(v, 10, 64)
+		case "pkt_dts_time":
+			err = fi.DTSTime.Parse(v)
+		case "duration_time", "pkt_duration_time":
+			err = fi.Duration.Parse(v)
+		case "pkt_size":
+			fi.Size, err = strconv.Atoi(v)
+		case "pict_type":
+			fi.PictType = v
+		case "interlaced_frame":
+			fi.Interlaced = v == "1"
+		case "top_field_first":
+			fi.TopFieldFirst = v == "1"
+		}
+
+		if err != nil {
+			break
+		}
+	}
+	return err
+}
+
+// PacketInfo is the information ffprobe reports about a single demuxed packet
+type PacketInfo struct {
+	// MediaType indicates if the packet is video, audio, etc
+	MediaType MediaType
+
+	// StreamIndex is the index of the stream the packet belongs to
+	StreamIndex int
+
+	// PTS is the presentation timestamp in stream time base units
+	PTS int64
+
+	// HasPTS is false when ffprobe reported the presentation timestamp as N/A, in which
+	// case PTS and PTSTime are zero
+	HasPTS bool
+
+	// PTSTime is the presentation timestamp
+	PTSTime Time
+
+	// DTS is the decoding timestamp in stream time base units
+	DTS int64
+
+	// DTSTime is the decoding timestamp
+	DTSTime Time
+
+	// Duration is the length of the packet
+	Duration Time
+
+	// Size is the size of the packet in bytes
+	Size int
+
+	// Pos is the byte position of the packet in the input
+	Pos int64
+
+	// Flags is the raw packet flag string reported by ffprobe (K for key, D for discard)
+	Flags string
+}
+
+// KeyFrame is true when the packet contains a key frame
+func (pi *PacketInfo) KeyFrame() bool { return strings.Contains(pi.Flags, "K") }
+
+func (pi *PacketInfo) parse(values map[string]string) (err error) {
+	for k, v := range values {
+		if v == "N/A" || v == "" {
+			continue
+		}
+
+		switch k {
+		case "codec_type":
+			pi.MediaType, err = MediaTypeString(v)
+		case "stream_index":
+			pi.StreamIndex, err = strconv.Atoi(v)
+		case "pts":
+			pi.PTS, err = strconv.ParseInt(v, 10, 64)
+			pi.HasPTS = err == nil
+		case "pts_time":
+			err = pi.PTSTime.Parse(v)
+		case "dts":
+			pi.DTS, err = strconv.ParseInt(v, 10, 64)
+		case "dts_time":
+			err = pi.DTSTime.Parse(v)
+		case "duration_time":
+			err = pi.Duration.Parse(v)
+		case "size":
+			pi.Size, err = strconv.Atoi(v)
+		case "pos":
+			pi.Pos, err = strconv.ParseInt(v, 10, 64)
+		case "flags":
+			pi.Flags = v
+		}
+
+		if err != nil {
+			break
+		}
+	}
+	return err
+}
+
+// ProbeOption alters the ffprobe command run by ProbeFrames and ProbePackets
+type ProbeOption func(*ProbeReader) error
+
+// SelectStreamsOption limits probing to the streams matching the ffmpeg stream
+// specifier (for instance "v:0" for the first video stream).  This uses the ffprobe
+// -select_streams option
+func SelectStreamsOption(specifier string) ProbeOption {
+	return func(pr *ProbeReader) error {
+		pr.proc.AppendArgs("-select_streams", specifier)
+		return nil
+	}
+}
+
+// ReadIntervalsOption limits probing to the given intervals.  The intervals string
+// is passed directly to the ffprobe -read_intervals option (for instance "10%+20")
+func ReadIntervalsOption(intervals string) ProbeOption {
+	return func(pr *ProbeReader) error {
+		pr.proc.AppendArgs("-read_intervals", intervals)
+		return nil
+	}
+}
+
+// ProbeReader reads the frame and packet records emitted by a running ffprobe
+// process.  Records are read one at a time using Scan, similar to a bufio.Scanner
+type ProbeReader struct {
+	ctx     context.Context
+	proc    cmd.Process
+	reader  *io.PipeReader
+	scanner *bufio.Scanner
+	stderr  *bytes.Buffer
+	done    chan struct{}
+	closed  bool
+
+	frame  *FrameInfo
+	packet *PacketInfo
+	err    error
+}
+
+// ProbeFrames starts ffprobe with -show_frames for the named input and returns
+// a ProbeReader that yields a FrameInfo for every decoded frame.  Decoding every
+// frame can take as long as a transcode, so the ffprobe process is killed if the
+// context is done before all the frames are read
+func ProbeFrames(ctx context.Context, filename string, options ...ProbeOption) (*ProbeReader, error) {
+	return newProbeReader(ctx, Ffprobe, filename, []string{"-show_frames"}, options...)
+}
+
+// ProbePackets starts ffprobe with -show_packets for the named input and returns
+// a ProbeReader that yields a PacketInfo for every packet in the input.  Packets are
+// not decoded so this is much faster than ProbeFrames
+func ProbePackets(ctx context.Context, filename string, options ...ProbeOption) (*ProbeReader, error) {
+	return newProbeReader(ctx, Ffprobe, filename, []string{"-show_packets"}, options...)
+}
+
+func newProbeReader(ctx context.Context, ffprobe cmd.Command, filename string, shows []string, options ...ProbeOption) (pr *ProbeReader, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	pr = &ProbeReader{
+		ctx:    ctx,
+		proc:   ffprobe.Process(),
+		stderr: bytes.NewBuffer(nil),
+		done:   make(chan struct{}),
+	}
+
+	// the compact writer emits one record per line which can be parsed as
+	// ffprobe produces it, the last -print_format on the command line wins
+	pr.proc.AppendArgs("-print_format", "compact")
+	pr.proc.AppendArgs(shows...)
+	for _, option := range options {
+		err = option(pr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	pr.proc.AppendArgs(filename)
+
+	reader, writer := io.Pipe()
+	pr.reader = reader
+	pr.scanner = bufio.NewScanner(reader)
+	pr.proc.Stdout(writer)
+	pr.proc.Stderr(pr.stderr)
+	err = notFound(pr.proc.Start(), ErrFfprobeNotFound)
+	if err == nil {
+		watchContext(ctx, pr.proc, pr.done)
+	} else {
+		pr = nil
+	}
+	return pr, err
+}
+
+// Scan advances the ProbeReader to the next frame or packet record.  Scan returns
+// false when there are no more records or an error occurred, in which case Err
+// will return the error
+func (pr *ProbeReader) Scan() bool {
+	pr.frame = nil
+	pr.packet = nil
+	for !pr.closed {
+		if !pr.scanner.Scan() {
+			pr.err = pr.scanner.Err()
+			pr.finish(false)
+			return false
+		}
+
+		section, values := parseCompact(pr.scanner.Text())
+		switch section {
+		case "frame":
+			pr.frame = &FrameInfo{}
+			pr.err = pr.frame.parse(values)
+		case "packet":
+			pr.packet = &PacketInfo{}
+			pr.err = pr.packet.parse(values)
+		default:
+			continue
+		}
+
+		if pr.err != nil {
+			pr.err = fmt.Errorf("failed to parse %s: %v", section, pr.err)
+			pr.finish(true)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// Frame returns the current frame record, or nil if the current record is not a frame
+func (pr *ProbeReader) Frame() *FrameInfo { return pr.frame }
+
+// Packet returns the current packet record, or nil if the current record is not a packet
+func (pr *ProbeReader) Packet() *PacketInfo { return pr.packet }
+
+// Err returns the first error encountered while reading records or running ffprobe
+func (pr *ProbeReader) Err() error { return pr.err }
+
+// Close stops the ffprobe process if it is still running.  Close only needs to be called
+// when the records are not read until Scan returns false
+func (pr *ProbeReader) Close() error {
+	pr.finish(true)
+	return pr.err
+}
+
+func (pr *ProbeReader) finish(kill bool) {
+	if pr.closed {
+		return
+	}
+	pr.closed = true
+
+	if kill {
+		pr.proc.Kill()
+	}
+	pr.reader.CloseWithError(io.EOF)
+	err := pr.proc.Wait()
+	close(pr.done)
+
+	if pr.err == nil && err != nil && !kill {
+		if pr.ctx.Err() != nil {
+			pr.err = pr.ctx.Err()
+		} else {
+			pr.err = fmt.Errorf("%s", strings.TrimSpace(pr.stderr.String()))
+		}
+	}
+}
+
+// parseCompact splits a line written by the ffprobe compact writer into its section
+// name and key/value pairs.  Separators escaped with a backslash are kept in the value
+func parseCompact(line string) (section string, values map[string]string) {
+	values = make(map[string]string)
+	fields := []string{}
+	field := &strings.Builder{}
+	escaped := false
+	for _, r := range line {
+		if escaped {
+			field.WriteRune(r)
+			escaped = false
+		} else if r == '\\' {
+			escaped = true
+		} else if r == '|' {
+			fields = append(fields, field.String())
+			field.Reset()
+		} else {
+			field.WriteRune(r)
+		}
+	}
+	fields = append(fields, field.String())
+
+	section = fields[0]
+	for _, f := range fields[1:] {
+		if index := strings.Index(f, "="); index > 0 {
+			values[f[:index]] = f[index+1:]
+		}
+	}
+	return section, values
+}
@@ -0,0 +1,100 @@
+package ffmpeg
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/mh-orange/cmd"
+)
+
+func TestParseCompact(t *testing.T) {
+	tests := []struct {
+		input       string
+		wantSection string
+		wantValues  map[string]string
+	}{
+		{"frame|key_frame=1|pict_type=I", "frame", map[string]string{"key_frame": "1", "pict_type": "I"}},
+		{`format|format_name=mov\|mp4`, "format", map[string]string{"format_name": "mov|mp4"}},
+		{"packet", "packet", map[string]string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			section, values := parseCompact(test.input)
+			if section != test.wantSection {
+				t.Errorf("want section %q got %q", test.wantSection, section)
+			}
+
+			if !reflect.DeepEqual(test.wantValues, values) {
+				t.Errorf("want %v got %v", test.wantValues, values)
+			}
+		})
+	}
+}
+
+func TestProbeFrames(t *testing.T) {
+	oldFfprobe := Ffprobe
+	input, err := ioutil.ReadFile("testdata/frames1.txt")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	Ffprobe = &cmd.TestCmd{Stdout: input}
+
+	want := []FrameInfo{
+		{Video, 0, true, 0, 0, 0, 0, Time(33367000), 45612, "I", false, false},
+		{Video, 0, false, 3003, Time(100100000), 1001, Time(33367000), Time(33367000), 12880, "P", true, true},
+		{Video, 0, false, 1001, Time(33367000), 0, 0, Time(33367000), 3021, "B", false, false},
+	}
+
+	pr, err := ProbeFrames(context.Background(), "frames1.mp4", SelectStreamsOption("v:0"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got := []FrameInfo{}
+	for pr.Scan() {
+		if pr.Packet() != nil {
+			t.Errorf("Unexpected packet")
+		}
+		got = append(got, *pr.Frame())
+	}
+
+	if pr.Err() != nil {
+		t.Errorf("Unexpected error: %v", pr.Err())
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+	Ffprobe = oldFfprobe
+}
+
+func TestProbePackets(t *testing.T) {
+	oldFfprobe := Ffprobe
+	input, err := ioutil.ReadFile("testdata/packets1.txt")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	Ffprobe = &cmd.TestCmd{Stdout: input}
+
+	pr, err := ProbePackets(context.Background(), "packets1.mp4")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	keyFrames := []bool{}
+	for pr.Scan() {
+		keyFrames = append(keyFrames, pr.Packet().KeyFrame())
+	}
+
+	if pr.Err() != nil {
+		t.Errorf("Unexpected error: %v", pr.Err())
+	}
+
+	if want := []bool{true, true, false}; !reflect.DeepEqual(want, keyFrames) {
+		t.Errorf("want %v got %v", want, keyFrames)
+	}
+	Ffprobe = oldFfprobe
+}
@@ -0,0 +1,80 @@
+package ffmpeg
+
+import "sync"
+
+// progressBroadcaster delivers TranscodeInfo updates to any number of subscribers.
+// Each subscriber channel holds at most one value and a slow subscriber only ever
+// misses intermediate updates: the most recent update replaces any value that has
+// not yet been received
+type progressBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan TranscodeInfo]struct{}
+	latest      TranscodeInfo
+	published   bool
+	closed      bool
+}
+
+func newProgressBroadcaster() *progressBroadcaster {
+	return &progressBroadcaster{subscribers: make(map[chan TranscodeInfo]struct{})}
+}
+
+// subscribe returns a new subscriber channel and a function to cancel the subscription.
+// If an update has already been published then the channel starts out holding it
+func (pb *progressBroadcaster) subscribe() (<-chan TranscodeInfo, func()) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	ch := make(chan TranscodeInfo, 1)
+	if pb.published {
+		ch <- pb.latest
+	}
+
+	if pb.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	pb.subscribers[ch] = struct{}{}
+	return ch, func() {
+		pb.mu.Lock()
+		defer pb.mu.Unlock()
+		if _, found := pb.subscribers[ch]; found {
+			delete(pb.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (pb *progressBroadcaster) publish(info TranscodeInfo) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	pb.latest = info
+	pb.published = true
+	for ch := range pb.subscribers {
+		send(ch, info)
+	}
+}
+
+// close closes every subscriber channel.  Since publish always leaves the latest
+// update in the channel, subscribers receive the final update before the close
+func (pb *progressBroadcaster) close() {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	pb.closed = true
+	for ch := range pb.subscribers {
+		delete(pb.subscribers, ch)
+		close(ch)
+	}
+}
+
+// send replaces any unreceived value in the channel with info.  Only the
+// broadcaster sends on subscriber channels so the send can never block
+func send(ch chan TranscodeInfo, info TranscodeInfo) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- info
+}
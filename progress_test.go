@@ -0,0 +1,54 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func drain(ch <-chan TranscodeInfo) (frames []int) {
+	for info := range ch {
+		frames = append(frames, info.Frame)
+	}
+	return frames
+}
+
+func TestProgressBroadcaster(t *testing.T) {
+	pb := newProgressBroadcaster()
+	slow, _ := pb.subscribe()
+	fast, _ := pb.subscribe()
+	gone, unsubscribe := pb.subscribe()
+	unsubscribe()
+	unsubscribe()
+
+	pb.publish(TranscodeInfo{Frame: 1})
+	if got := <-fast; got.Frame != 1 {
+		t.Errorf("want frame 1 got %d", got.Frame)
+	}
+
+	pb.publish(TranscodeInfo{Frame: 2})
+	pb.publish(TranscodeInfo{Frame: 3})
+	late, _ := pb.subscribe()
+	pb.close()
+
+	tests := []struct {
+		name string
+		ch   <-chan TranscodeInfo
+		want []int
+	}{
+		{"slow", slow, []int{3}},
+		{"fast", fast, []int{3}},
+		{"late", late, []int{3}},
+		{"unsubscribed", gone, nil},
+	}
+
+	for _, test := range tests {
+		if got := drain(test.ch); !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%s: want %v got %v", test.name, test.want, got)
+		}
+	}
+
+	closed, _ := pb.subscribe()
+	if got := drain(closed); !reflect.DeepEqual([]int{3}, got) {
+		t.Errorf("want [3] got %v", got)
+	}
+}
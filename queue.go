@@ -0,0 +1,454 @@
+package ffmpeg
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+var (
+	// ErrQueueClosed is returned when submitting a job to a Queue that has been closed
+	ErrQueueClosed = errors.New("queue is closed")
+
+	// ErrJobCanceled is the error of a QueuedJob that was canceled before it started
+	ErrJobCanceled = errors.New("job canceled")
+)
+
+// JobTranscoder starts transcode jobs.  Transcoder, TestTranscoder, RemoteTranscoder
+// and RetryTranscoder are all JobTranscoders
+type JobTranscoder interface {
+	Transcode(options ...TranscoderOption) (TranscodeJob, error)
+}
+
+// QueuedJob is a job that has been submitted to a Queue
+type QueuedJob struct {
+	// ID uniquely identifies the job within the Queue
+	ID string
+
+	// Tenant is the owner of the job.  Jobs of the same priority are scheduled
+	// round-robin between tenants
+	Tenant string
+
+	// Priority of the job, jobs with a higher priority are started first
+	Priority int
+
+	// Spec is the description of the transcode job
+	Spec JobSpec
+
+	mu       sync.Mutex
+	queue    *Queue
+	state    JobState
+	job      TranscodeJob
+	err      error
+	canceled bool
+	started  chan struct{}
+	done     chan struct{}
+}
+
+func newQueuedJob(id, tenant string, priority int, spec JobSpec) *QueuedJob {
+	return &QueuedJob{
+		ID:       id,
+		Tenant:   tenant,
+		Priority: priority,
+		Spec:     spec,
+		state:    JobQueued,
+		started:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// State returns the current state of the job
+func (qj *QueuedJob) State() JobState {
+	qj.mu.Lock()
+	defer qj.mu.Unlock()
+	return qj.state
+}
+
+// Job returns the underlying TranscodeJob, which can be used to monitor progress.
+// Job returns nil until the job has started
+func (qj *QueuedJob) Job() TranscodeJob {
+	qj.mu.Lock()
+	defer qj.mu.Unlock()
+	return qj.job
+}
+
+// Started returns a channel that is closed once the job leaves the queue, either
+// because it started running or because it was canceled
+func (qj *QueuedJob) Started() <-chan struct{} {
+	return qj.started
+}
+
+// Err returns the error that caused the job to fail, if any
+func (qj *QueuedJob) Err() error {
+	qj.mu.Lock()
+	defer qj.mu.Unlock()
+	return qj.err
+}
+
+// Wait blocks until the job completes and returns its error
+func (qj *QueuedJob) Wait() error {
+	<-qj.done
+	return qj.Err()
+}
+
+// Cancel removes the job from the queue if it has not started yet, or
+// cancels the running TranscodeJob.  Cancel does nothing once the job has finished
+func (qj *QueuedJob) Cancel() {
+	select {
+	case <-qj.done:
+		return
+	default:
+	}
+
+	qj.mu.Lock()
+	job := qj.job
+	queue := qj.queue
+	qj.canceled = true
+	qj.mu.Unlock()
+
+	if job != nil {
+		job.Cancel()
+	} else if queue != nil && queue.unqueue(qj) {
+		queue.update(qj, JobCanceled, nil, ErrJobCanceled)
+	}
+}
+
+func (qj *QueuedJob) record() JobRecord {
+	qj.mu.Lock()
+	defer qj.mu.Unlock()
+	record := JobRecord{
+		ID:       qj.ID,
+		Tenant:   qj.Tenant,
+		Priority: qj.Priority,
+		Spec:     qj.Spec,
+		State:    qj.state,
+	}
+
+	if qj.err != nil {
+		record.Error = qj.err.Error()
+	}
+	return record
+}
+
+// setState updates the state of the job and closes the started and done
+// channels as the job moves through its lifecycle
+func (qj *QueuedJob) setState(state JobState, job TranscodeJob, err error) {
+	qj.mu.Lock()
+	defer qj.mu.Unlock()
+	if qj.state == JobQueued {
+		close(qj.started)
+	}
+
+	qj.state = state
+	qj.err = err
+	if job != nil {
+		qj.job = job
+	}
+
+	if state != JobRunning {
+		close(qj.done)
+	}
+}
+
+// Queue runs transcode jobs with a bounded number of concurrent ffmpeg processes.
+// Jobs with a higher priority are started first.  Jobs of equal priority are started
+// round-robin between tenants and in the order they were submitted for each tenant,
+// so one tenant submitting a large batch does not starve everyone else
+type Queue struct {
+	transcoder JobTranscoder
+	workers    int
+	store      JobStore
+
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	nextID  uint64
+	serial  uint64
+	served  map[string]uint64
+	pending []*QueuedJob
+	jobs    map[string]*QueuedJob
+	running int
+	closed  bool
+}
+
+// NewQueue returns a Queue that runs at most workers jobs at a time using the
+// given transcoder.  If transcoder is nil then NewTranscoder() is used
+func NewQueue(workers int, transcoder JobTranscoder) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+
+	if transcoder == nil {
+		transcoder = NewTranscoder()
+	}
+
+	return &Queue{
+		transcoder: transcoder,
+		workers:    workers,
+		served:     make(map[string]uint64),
+		jobs:       make(map[string]*QueuedJob),
+	}
+}
+
+// NewPersistentQueue is like NewQueue except every job is saved in the store as it
+// changes state.  Jobs that were in the store when the queue is created are restored:
+// queued jobs are queued again, jobs that were running when the process stopped have
+// their partially written outputs removed and are queued again, and finished jobs are
+// available from Job and Jobs until they are removed
+func NewPersistentQueue(workers int, transcoder JobTranscoder, store JobStore) (*Queue, error) {
+	records, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	// restore the jobs in the order they were submitted
+	sort.Slice(records, func(i, j int) bool {
+		iID, _ := strconv.ParseUint(records[i].ID, 10, 64)
+		jID, _ := strconv.ParseUint(records[j].ID, 10, 64)
+		return iID < jID
+	})
+
+	q := NewQueue(workers, transcoder)
+	q.store = store
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, record := range records {
+		if id, _ := strconv.ParseUint(record.ID, 10, 64); id > q.nextID {
+			q.nextID = id
+		}
+
+		qj := newQueuedJob(record.ID, record.Tenant, record.Priority, record.Spec)
+		qj.queue = q
+		q.jobs[qj.ID] = qj
+		switch record.State {
+		case JobRunning:
+			for _, filename := range record.Spec.Filenames() {
+				if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+					log.Printf("failed to remove partial output %s: %v", filename, err)
+				}
+			}
+			if err := q.save(qj); err != nil {
+				return nil, err
+			}
+			fallthrough
+		case JobQueued:
+			q.pending = append(q.pending, qj)
+		default:
+			var err error
+			if record.Error != "" {
+				err = errors.New(record.Error)
+			}
+			qj.setState(record.State, nil, err)
+		}
+	}
+	q.dispatch()
+	return q, nil
+}
+
+// Submit adds a job to the queue and returns immediately.  The job is started
+// once a worker is available.  The job is not queued if it cannot be saved in
+// the JobStore of a persistent queue, and the store's error is returned
+func (q *Queue) Submit(tenant string, priority int, spec JobSpec) (*QueuedJob, error) {
+	q.mu.Lock()
+	q.nextID++
+	id := strconv.FormatUint(q.nextID, 10)
+	q.mu.Unlock()
+	return q.submit(newQueuedJob(id, tenant, priority, spec))
+}
+
+func (q *Queue) submit(qj *QueuedJob) (*QueuedJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, ErrQueueClosed
+	}
+
+	if err := q.save(qj); err != nil {
+		return nil, err
+	}
+
+	qj.queue = q
+	q.jobs[qj.ID] = qj
+	q.pending = append(q.pending, qj)
+	q.dispatch()
+	return qj, nil
+}
+
+// Job returns the job with the given ID
+func (q *Queue) Job(id string) (qj *QueuedJob, found bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	qj, found = q.jobs[id]
+	return qj, found
+}
+
+// Jobs returns every job known to the queue, in no particular order
+func (q *Queue) Jobs() []*QueuedJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]*QueuedJob, 0, len(q.jobs))
+	for _, qj := range q.jobs {
+		jobs = append(jobs, qj)
+	}
+	return jobs
+}
+
+// Remove forgets a finished job.  Jobs are kept by the queue until they are
+// removed so their state can be inspected after they finish.  If the job cannot
+// be deleted from the JobStore of a persistent queue the store's error is returned
+// and the job is kept
+func (q *Queue) Remove(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if qj, found := q.jobs[id]; found {
+		if state := qj.State(); state != JobQueued && state != JobRunning {
+			if q.store != nil {
+				if err := q.store.Delete(id); err != nil {
+					return err
+				}
+			}
+			delete(q.jobs, id)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of jobs waiting for a worker and the number of jobs running
+func (q *Queue) Len() (queued, running int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending), q.running
+}
+
+// Close stops the queue from accepting new jobs, cancels every job that has not
+// started and waits for the running jobs to finish.  Jobs that have not started
+// are not canceled in the JobStore of a persistent queue, so they will run when
+// the queue is restored
+func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	pending := q.pending
+	q.pending = nil
+	q.mu.Unlock()
+
+	for _, qj := range pending {
+		qj.setState(JobCanceled, nil, ErrJobCanceled)
+	}
+	q.wg.Wait()
+}
+
+// update changes the state of the job and saves it.  Nothing is waiting on the
+// state change, so a failure to save is only logged
+func (q *Queue) update(qj *QueuedJob, state JobState, job TranscodeJob, err error) {
+	qj.setState(state, job, err)
+	if err := q.save(qj); err != nil {
+		log.Printf("failed to save job %s: %v", qj.ID, err)
+	}
+}
+
+// save writes the job to the store, if the queue has one
+func (q *Queue) save(qj *QueuedJob) error {
+	if q.store != nil {
+		return q.store.Save(qj.record())
+	}
+	return nil
+}
+
+// unqueue removes the job from the list of pending jobs.  False is returned
+// if the job was not pending
+func (q *Queue) unqueue(qj *QueuedJob) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, pending := range q.pending {
+		if pending == qj {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// next removes and returns the pending job that should run next.  The queue
+// lock must be held
+func (q *Queue) next() *QueuedJob {
+	best := -1
+	for i, qj := range q.pending {
+		if best < 0 || qj.Priority > q.pending[best].Priority {
+			best = i
+		} else if qj.Priority == q.pending[best].Priority && q.served[qj.Tenant] < q.served[q.pending[best].Tenant] {
+			best = i
+		}
+	}
+
+	qj := q.pending[best]
+	q.pending = append(q.pending[:best], q.pending[best+1:]...)
+	q.serial++
+	q.served[qj.Tenant] = q.serial
+	return qj
+}
+
+// dispatch starts as many pending jobs as there are free workers.  The queue
+// lock must be held
+func (q *Queue) dispatch() {
+	for !q.closed && q.running < q.workers && len(q.pending) > 0 {
+		qj := q.next()
+		q.running++
+		q.wg.Add(1)
+		go q.run(qj)
+	}
+}
+
+func (q *Queue) run(qj *QueuedJob) {
+	defer q.wg.Done()
+	defer func() {
+		q.mu.Lock()
+		q.running--
+		q.dispatch()
+		q.mu.Unlock()
+	}()
+
+	qj.mu.Lock()
+	canceled := qj.canceled
+	qj.mu.Unlock()
+	if canceled {
+		q.update(qj, JobCanceled, nil, ErrJobCanceled)
+		return
+	}
+
+	options, err := qj.Spec.Options()
+	var job TranscodeJob
+	if err == nil {
+		job, err = q.transcoder.Transcode(options...)
+	}
+
+	if err != nil {
+		q.update(qj, JobFailed, nil, err)
+		return
+	}
+
+	q.update(qj, JobRunning, job, nil)
+	qj.mu.Lock()
+	canceled = qj.canceled
+	qj.mu.Unlock()
+	if canceled {
+		job.Cancel()
+	}
+
+	// the job's own result decides the final state, a job that completed before
+	// the cancellation reached it is done
+	err = job.Wait()
+	qj.mu.Lock()
+	canceled = qj.canceled
+	qj.mu.Unlock()
+
+	if err == nil {
+		q.update(qj, JobDone, job, nil)
+	} else if canceled || errors.Is(err, ErrStopped) {
+		q.update(qj, JobCanceled, job, err)
+	} else {
+		q.update(qj, JobFailed, job, err)
+	}
+}
@@ -0,0 +1,173 @@
+package ffmpeg
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+// blockingJob is a TranscodeJob that runs until it is released or canceled
+type blockingJob struct {
+	*TestJob
+	release chan error
+}
+
+func (bj *blockingJob) Cancel() {
+	bj.release <- io.EOF
+}
+
+func (bj *blockingJob) Wait() error {
+	return <-bj.release
+}
+
+// blockingTranscoder hands every job it starts to the test
+type blockingTranscoder struct {
+	started chan *blockingJob
+}
+
+func (bt *blockingTranscoder) Transcode(options ...TranscoderOption) (TranscodeJob, error) {
+	job := &blockingJob{&TestJob{}, make(chan error, 1)}
+	bt.started <- job
+	return job, nil
+}
+
+func runningJob(t *testing.T, queue *Queue, job *blockingJob) *QueuedJob {
+	// the job is handed over before it is recorded in the QueuedJob
+	for i := 0; i < 100; i++ {
+		for _, qj := range queue.Jobs() {
+			if qj.Job() == job {
+				return qj
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("started job not found in queue")
+	return nil
+}
+
+func TestQueueScheduling(t *testing.T) {
+	transcoder := &blockingTranscoder{started: make(chan *blockingJob)}
+	queue := NewQueue(1, transcoder)
+
+	submit := func(tenant string, priority int) *QueuedJob {
+		qj, err := queue.Submit(tenant, priority, JobSpec{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return qj
+	}
+
+	a1 := submit("a", 0)
+	job := <-transcoder.started
+	if got := runningJob(t, queue, job); got != a1 {
+		t.Fatalf("want job %s running got %s", a1.ID, got.ID)
+	}
+
+	a2 := submit("a", 0)
+	a3 := submit("a", 0)
+	b1 := submit("b", 0)
+	c1 := submit("c", 5)
+	canceled := submit("b", 10)
+	canceled.Cancel()
+	if err := canceled.Wait(); err != ErrJobCanceled || canceled.State() != JobCanceled {
+		t.Errorf("want canceled job got %v %v", canceled.State(), err)
+	}
+
+	if queued, running := queue.Len(); queued != 4 || running != 1 {
+		t.Errorf("want 4 queued 1 running got %d %d", queued, running)
+	}
+
+	for _, want := range []*QueuedJob{c1, b1, a2, a3} {
+		job.release <- nil
+		job = <-transcoder.started
+		if got := runningJob(t, queue, job); got != want {
+			t.Errorf("want job %s got %s", want.ID, got.ID)
+		}
+	}
+
+	a3.Cancel()
+	if err := a3.Wait(); err != io.EOF || a3.State() != JobCanceled {
+		t.Errorf("want canceled job got %v %v", a3.State(), err)
+	}
+
+	if a1.State() != JobDone || a1.Wait() != nil {
+		t.Errorf("want done got %v", a1.State())
+	}
+
+	queue.Remove(a1.ID)
+	if _, found := queue.Job(a1.ID); found {
+		t.Errorf("expected job to be removed")
+	}
+
+	queue.Close()
+	if _, err := queue.Submit("a", 0, JobSpec{}); err != ErrQueueClosed {
+		t.Errorf("want %v got %v", ErrQueueClosed, err)
+	}
+}
+
+func TestQueueFailed(t *testing.T) {
+	queue := NewQueue(2, &TestTranscoder{JobErr: io.EOF})
+	qj, err := queue.Submit("", 0, JobSpec{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err = qj.Wait(); err != io.EOF || qj.State() != JobFailed {
+		t.Errorf("want failed job got %v %v", qj.State(), err)
+	}
+
+	queue = NewQueue(2, &TestTranscoder{TranscodeErr: io.ErrUnexpectedEOF})
+	qj, _ = queue.Submit("", 0, JobSpec{})
+	if err = qj.Wait(); err != io.ErrUnexpectedEOF || qj.Job() != nil {
+		t.Errorf("want job that failed to start got %v", err)
+	}
+	queue.Close()
+}
+
+// finishingJob is a TranscodeJob that calls finish after it has completed, but
+// before Wait returns
+type finishingJob struct {
+	*TestJob
+	finish func()
+}
+
+func (fj *finishingJob) Wait() error {
+	fj.finish()
+	return nil
+}
+
+type finishingTranscoder struct {
+	finish func()
+}
+
+func (ft *finishingTranscoder) Transcode(options ...TranscoderOption) (TranscodeJob, error) {
+	return &finishingJob{&TestJob{}, ft.finish}, nil
+}
+
+func TestQueueCancelFinished(t *testing.T) {
+	transcoder := &finishingTranscoder{}
+	queue := NewQueue(1, transcoder)
+	defer queue.Close()
+
+	var qj *QueuedJob
+	submitted := make(chan struct{})
+	transcoder.finish = func() {
+		<-submitted
+		qj.Cancel()
+	}
+
+	qj, err := queue.Submit("", 0, JobSpec{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	close(submitted)
+
+	if err := qj.Wait(); err != nil || qj.State() != JobDone {
+		t.Errorf("want done job got %v %v", qj.State(), err)
+	}
+
+	qj.Cancel()
+	if err := qj.Err(); err != nil || qj.State() != JobDone {
+		t.Errorf("want done job got %v %v", qj.State(), err)
+	}
+}
@@ -0,0 +1,680 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrJobNotFound is returned when controlling a job that a Worker is not running
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrPathNotAllowed is returned by a Worker for a JobSpec that names a file outside
+// of the worker's Root, or an input URL that could read local files
+var ErrPathNotAllowed = errors.New("path not allowed")
+
+// networkSchemes are the input URL schemes that a Worker with a Root accepts.  Other
+// ffmpeg protocols (file, concat, subfile and so on) can read any local file
+var networkSchemes = map[string]bool{
+	"http":  true,
+	"https": true,
+	"rtmp":  true,
+	"rtmps": true,
+	"rtsp":  true,
+	"srt":   true,
+	"udp":   true,
+	"tcp":   true,
+}
+
+// remoteErrors are the errors that keep their identity when they are sent from a
+// Worker to a RemoteTranscoder, so that errors.Is works on both sides
+var remoteErrors = []error{
+	context.Canceled,
+	context.DeadlineExceeded,
+	ErrFfmpegNotFound,
+	ErrFfprobeNotFound,
+	ErrInputNotFound,
+	ErrPermissionDenied,
+	ErrInvalidData,
+	ErrUnknownEncoder,
+	ErrUnsupportedCodec,
+	ErrNonMonotonicDTS,
+	ErrMuxingQueueFull,
+	ErrNoSpace,
+	ErrKilled,
+	ErrStalled,
+	ErrStopped,
+	ErrSignalUnsupported,
+	ErrUnsupported,
+	ErrJobNotFound,
+	ErrPathNotAllowed,
+	ErrNoSpec,
+	ErrQueueClosed,
+	ErrJobCanceled,
+}
+
+// remoteError is the JSON form of an error.  Cause is the message of the matching
+// remoteErrors entry.  When TranscodeError is set the remaining fields are those of
+// a *TranscodeError and Message is the message of its Err
+type remoteError struct {
+	Message        string   `json:"message,omitempty"`
+	Cause          string   `json:"cause,omitempty"`
+	TranscodeError bool     `json:"transcodeError,omitempty"`
+	ExitCode       int      `json:"exitCode"`
+	Command        string   `json:"command,omitempty"`
+	Log            []string `json:"log,omitempty"`
+}
+
+func encodeRemoteError(err error) *remoteError {
+	if err == nil {
+		return nil
+	}
+
+	re := &remoteError{Message: err.Error()}
+	var te *TranscodeError
+	if errors.As(err, &te) {
+		re.TranscodeError = true
+		re.ExitCode = te.ExitCode
+		re.Command = te.Command
+		re.Log = te.Log
+		re.Message = ""
+		if te.Err != nil {
+			re.Message = te.Err.Error()
+		}
+		err = te.Cause
+	}
+
+	for _, sentinel := range remoteErrors {
+		if err != nil && errors.Is(err, sentinel) {
+			re.Cause = sentinel.Error()
+			break
+		}
+	}
+	return re
+}
+
+func (re *remoteError) decode() error {
+	if re == nil {
+		return nil
+	}
+
+	var cause error
+	for _, sentinel := range remoteErrors {
+		if re.Cause != "" && sentinel.Error() == re.Cause {
+			cause = sentinel
+			break
+		}
+	}
+
+	if re.TranscodeError {
+		te := &TranscodeError{
+			ExitCode: re.ExitCode,
+			Command:  re.Command,
+			Log:      re.Log,
+			Cause:    cause,
+		}
+
+		if re.Message != "" {
+			te.Err = errors.New(re.Message)
+		}
+		return te
+	} else if cause == nil {
+		return errors.New(re.Message)
+	} else if re.Message == re.Cause {
+		return cause
+	}
+	return &causedError{message: re.Message, cause: cause}
+}
+
+// causedError is an error received from a Worker that wraps one of the remoteErrors
+type causedError struct {
+	message string
+	cause   error
+}
+
+func (ce *causedError) Error() string { return ce.message }
+func (ce *causedError) Unwrap() error { return ce.cause }
+
+// remoteEvent is a single line of the newline delimited JSON stream that a Worker
+// sends for each job.  The stream starts with a "started" event, followed by any
+// number of "log" and "progress" events, and ends with a "done" event
+type remoteEvent struct {
+	Type     string           `json:"type"`
+	ID       string           `json:"id,omitempty"`
+	Command  string           `json:"command,omitempty"`
+	Log      *LogEntry        `json:"log,omitempty"`
+	Progress *TranscodeInfo   `json:"progress,omitempty"`
+	Result   *TranscodeResult `json:"result,omitempty"`
+	Error    *remoteError     `json:"error,omitempty"`
+}
+
+// eventWriter writes remoteEvents to an HTTP response, flushing after every event
+// so that they reach the client as they happen.  While holding is set events are
+// kept back, in held, until release is called
+type eventWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	flusher http.Flusher
+	err     error
+	holding bool
+	held    []remoteEvent
+}
+
+func newEventWriter(rw http.ResponseWriter) *eventWriter {
+	ew := &eventWriter{encoder: json.NewEncoder(rw)}
+	ew.flusher, _ = rw.(http.Flusher)
+	return ew
+}
+
+func (ew *eventWriter) write(event remoteEvent) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	if ew.holding {
+		ew.held = append(ew.held, event)
+	} else {
+		ew.send(event)
+	}
+}
+
+// release writes the event followed by any events that were held back
+func (ew *eventWriter) release(event remoteEvent) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	ew.send(event)
+	for _, held := range ew.held {
+		ew.send(held)
+	}
+	ew.holding, ew.held = false, nil
+}
+
+// send writes the event, the caller must hold mu.  Once writing fails (the client
+// has gone away) further events are discarded
+func (ew *eventWriter) send(event remoteEvent) {
+	if ew.err != nil {
+		return
+	}
+
+	ew.err = ew.encoder.Encode(event)
+	if ew.err == nil && ew.flusher != nil {
+		ew.flusher.Flush()
+	}
+}
+
+func writeRemoteError(rw http.ResponseWriter, status int, err error) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(encodeRemoteError(err))
+}
+
+func readRemoteError(resp *http.Response) error {
+	re := &remoteError{}
+	if err := json.NewDecoder(resp.Body).Decode(re); err != nil || re.Message == "" && re.Cause == "" {
+		return fmt.Errorf("remote worker: %s", resp.Status)
+	}
+	return re.decode()
+}
+
+// contextTranscoder is implemented by transcoders, such as Transcoder, that can tie a
+// job to a context
+type contextTranscoder interface {
+	TranscodeContext(ctx context.Context, options ...TranscoderOption) (TranscodeJob, error)
+}
+
+// Worker is an http.Handler that runs transcode jobs for RemoteTranscoders.  Jobs are
+// submitted by POSTing a JobSpec to /jobs, the response is a stream of the job's log
+// lines and progress updates that ends with its result.  A running job is controlled
+// by POSTing to /jobs/<id>/cancel, /jobs/<id>/stop?grace=<duration>, /jobs/<id>/pause
+// and /jobs/<id>/resume.  Job IDs are random, so they cannot be guessed by clients that
+// did not submit the job.  A job is canceled if the client goes away before it finishes.
+//
+// The file names in a JobSpec are opened by the worker, so clients and workers need
+// to share storage (or the inputs need to be URLs).  Outputs are overwritten, so a
+// Worker that is reachable by untrusted clients should set Root and Authorize
+type Worker struct {
+	// Root, if set, is the directory that the inputs and outputs of every job must be
+	// in.  Relative file names are resolved against Root and jobs that name a file
+	// outside of it (or an input URL that is not a network stream) are rejected with
+	// ErrPathNotAllowed.  Symbolic links inside Root are not resolved
+	Root string
+
+	// Authorize, if set, is called with the request and the JobSpec (after Root has
+	// been applied) before the job is started.  The job is rejected if it returns an
+	// error.  Authorize can authenticate clients and vet the parts of a JobSpec that
+	// Root does not cover, such as Args, FormatOptions and VideoFilter.  Authorize is
+	// also called for requests that control a job, with the spec of that job, and the
+	// request is rejected if it returns an error
+	Authorize func(req *http.Request, spec *JobSpec) error
+
+	transcoder JobTranscoder
+	mu         sync.Mutex
+	jobs       map[string]workerJob
+}
+
+// workerJob is a job that a Worker is running, along with the spec it was submitted with
+type workerJob struct {
+	job  TranscodeJob
+	spec JobSpec
+}
+
+// NewWorker returns a Worker that runs jobs with the transcoder
+func NewWorker(transcoder JobTranscoder) *Worker {
+	return &Worker{
+		transcoder: transcoder,
+		jobs:       make(map[string]workerJob),
+	}
+}
+
+func (w *Worker) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if req.Method != http.MethodPost || parts[0] != "jobs" {
+		writeRemoteError(rw, http.StatusNotFound, ErrJobNotFound)
+	} else if len(parts) == 1 {
+		w.submit(rw, req)
+	} else if len(parts) == 3 {
+		w.control(rw, req, parts[1], parts[2])
+	} else {
+		writeRemoteError(rw, http.StatusNotFound, ErrJobNotFound)
+	}
+}
+
+func (w *Worker) submit(rw http.ResponseWriter, req *http.Request) {
+	spec := JobSpec{}
+	if err := json.NewDecoder(req.Body).Decode(&spec); err != nil {
+		writeRemoteError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := w.authorize(req, &spec); err != nil {
+		writeRemoteError(rw, http.StatusForbidden, err)
+		return
+	}
+
+	options, err := spec.Options()
+	if err != nil {
+		writeRemoteError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	// log lines are held back until the started event is written.  They are buffered,
+	// rather than ew.mu being held while the job starts, so that a log handler called
+	// before Transcode returns does not block
+	ew := newEventWriter(rw)
+	ew.holding = true
+	options = append(options, LogHandlerOption(func(entry LogEntry) {
+		ew.write(remoteEvent{Type: "log", Log: &entry})
+	}))
+
+	var job TranscodeJob
+	if ct, ok := w.transcoder.(contextTranscoder); ok {
+		job, err = ct.TranscodeContext(req.Context(), options...)
+	} else {
+		job, err = w.transcoder.Transcode(options...)
+	}
+
+	if err != nil {
+		writeRemoteError(rw, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	id := w.add(job, spec)
+	defer w.remove(id)
+
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	rw.WriteHeader(http.StatusOK)
+	ew.release(remoteEvent{Type: "started", ID: id, Command: job.Inspect()})
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	go func() {
+		select {
+		case <-req.Context().Done():
+			job.Cancel()
+		case <-doneCh:
+		}
+	}()
+
+	updates, _ := job.Subscribe()
+	for info := range updates {
+		info := info
+		ew.write(remoteEvent{Type: "progress", Progress: &info})
+	}
+
+	err = job.Wait()
+	result := job.Result()
+	ew.write(remoteEvent{Type: "done", Result: &result, Error: encodeRemoteError(err)})
+}
+
+// authorize applies Root to the file names of the spec and then calls Authorize
+func (w *Worker) authorize(req *http.Request, spec *JobSpec) (err error) {
+	if w.Root != "" {
+		for i := range spec.Inputs {
+			in := &spec.Inputs[i]
+			if in.Filename != "" {
+				in.Filename, err = w.resolve(in.Filename)
+			} else if u, perr := url.Parse(in.URL); perr != nil || !networkSchemes[u.Scheme] {
+				err = fmt.Errorf("%w: %s", ErrPathNotAllowed, in.URL)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		for i := range spec.Outputs {
+			if spec.Outputs[i].Filename, err = w.resolve(spec.Outputs[i].Filename); err != nil {
+				return err
+			}
+		}
+	}
+
+	if w.Authorize != nil {
+		err = w.Authorize(req, spec)
+	}
+	return err
+}
+
+// resolve returns the absolute form of a file name, relative names being inside
+// Root, or an error wrapping ErrPathNotAllowed if the file is outside of Root
+func (w *Worker) resolve(name string) (string, error) {
+	root, err := filepath.Abs(w.Root)
+	if err != nil {
+		return "", err
+	}
+
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(root, name)
+	}
+	name = filepath.Clean(name)
+
+	rel, err := filepath.Rel(root, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrPathNotAllowed, name)
+	}
+	return name, nil
+}
+
+func (w *Worker) control(rw http.ResponseWriter, req *http.Request, id, action string) {
+	w.mu.Lock()
+	wj, found := w.jobs[id]
+	w.mu.Unlock()
+	if !found {
+		writeRemoteError(rw, http.StatusNotFound, ErrJobNotFound)
+		return
+	}
+
+	if w.Authorize != nil {
+		if err := w.Authorize(req, &wj.spec); err != nil {
+			writeRemoteError(rw, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	var err error
+	job := wj.job
+	switch action {
+	case "cancel":
+		job.Cancel()
+	case "stop":
+		var grace time.Duration
+		grace, err = time.ParseDuration(req.URL.Query().Get("grace"))
+		if err != nil {
+			writeRemoteError(rw, http.StatusBadRequest, err)
+			return
+		}
+		job.Stop(grace)
+	case "pause":
+		err = job.Pause()
+	case "resume":
+		err = job.Resume()
+	default:
+		writeRemoteError(rw, http.StatusNotFound, fmt.Errorf("unknown action %q", action))
+		return
+	}
+
+	if err != nil {
+		writeRemoteError(rw, http.StatusConflict, err)
+	} else {
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// add records the job under a new random ID
+func (w *Worker) add(job TranscodeJob, spec JobSpec) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.jobs[id] = workerJob{job: job, spec: spec}
+	return id
+}
+
+func (w *Worker) remove(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.jobs, id)
+}
+
+// RemoteTranscoder submits jobs to a Worker, usually running on another machine.
+// The returned TranscodeJobs behave like local ones: progress updates, log lines
+// and the result are streamed back from the worker, and errors keep their identity
+// for errors.Is (for instance ErrUnknownEncoder or ErrStopped).  RemoteTranscoder is
+// a JobTranscoder, so it can run the jobs of a Queue or be wrapped by a RetryTranscoder
+type RemoteTranscoder struct {
+	// URL is the base URL of the Worker
+	URL string
+
+	// Client makes the requests to the Worker, http.DefaultClient is used if Client is nil
+	Client *http.Client
+}
+
+var _ JobTranscoder = (*RemoteTranscoder)(nil)
+
+// NewRemoteTranscoder returns a RemoteTranscoder for the Worker at url
+func NewRemoteTranscoder(url string) *RemoteTranscoder {
+	return &RemoteTranscoder{URL: url}
+}
+
+// Transcode converts the options to a JobSpec and submits it to the worker.  Only
+// options that a JobSpec can describe are accepted: inputs and outputs with file names
+// or URLs, MapOption, MapProgramOption and VideoFilterOption.  Other options return an
+// error wrapping ErrNoSpec
+func (rt *RemoteTranscoder) Transcode(options ...TranscoderOption) (TranscodeJob, error) {
+	return rt.TranscodeContext(context.Background(), options...)
+}
+
+// TranscodeContext is like Transcode but the job is canceled, on the worker, when ctx
+// is done
+func (rt *RemoteTranscoder) TranscodeContext(ctx context.Context, options ...TranscoderOption) (TranscodeJob, error) {
+	spec, err := optionsSpec(options)
+	if err != nil {
+		return nil, err
+	}
+	return rt.TranscodeSpecContext(ctx, spec)
+}
+
+// TranscodeSpec submits the job to the worker.  It returns once the worker has started
+// ffmpeg, or with the error that prevented the job from starting
+func (rt *RemoteTranscoder) TranscodeSpec(spec JobSpec) (TranscodeJob, error) {
+	return rt.TranscodeSpecContext(context.Background(), spec)
+}
+
+// TranscodeSpecContext is like TranscodeSpec but the job is canceled, on the worker,
+// when ctx is done.  Wait then returns ctx.Err()
+func (rt *RemoteTranscoder) TranscodeSpecContext(ctx context.Context, spec JobSpec) (TranscodeJob, error) {
+	body, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := rt.post(ctx, "/jobs", body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, readRemoteError(resp)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	event := remoteEvent{}
+	if err = decoder.Decode(&event); err == nil && event.Type != "started" {
+		err = fmt.Errorf("remote worker: unexpected %q event", event.Type)
+	}
+
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	job := &remoteJob{
+		transcoder: rt,
+		id:         event.ID,
+		command:    event.Command,
+		ctx:        ctx,
+		log:        newJobLog(DefaultLogSize),
+		progress:   newProgressBroadcaster(),
+		doneCh:     make(chan struct{}),
+	}
+	job.progressCh, _ = job.progress.subscribe()
+	go job.run(resp.Body, decoder)
+	return job, nil
+}
+
+func (rt *RemoteTranscoder) post(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(rt.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := rt.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req.WithContext(ctx))
+}
+
+type remoteJob struct {
+	transcoder *RemoteTranscoder
+	id         string
+	command    string
+	ctx        context.Context
+	log        *jobLog
+
+	mu     sync.Mutex
+	result TranscodeResult
+	err    error
+
+	progress   *progressBroadcaster
+	progressCh <-chan TranscodeInfo
+	doneCh     chan struct{}
+}
+
+func (rj *remoteJob) run(body io.ReadCloser, decoder *json.Decoder) {
+	defer close(rj.doneCh)
+	defer rj.progress.close()
+	defer body.Close()
+
+	for {
+		event := remoteEvent{}
+		if err := decoder.Decode(&event); err != nil {
+			if rj.ctx.Err() != nil {
+				err = rj.ctx.Err()
+			} else if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+
+			rj.mu.Lock()
+			rj.err = err
+			rj.mu.Unlock()
+			return
+		}
+
+		switch event.Type {
+		case "log":
+			if event.Log != nil {
+				rj.log.add(*event.Log)
+			}
+		case "progress":
+			if event.Progress != nil {
+				rj.progress.publish(*event.Progress)
+			}
+		case "done":
+			rj.mu.Lock()
+			if event.Result != nil {
+				rj.result = *event.Result
+			}
+			rj.err = event.Error.decode()
+			rj.mu.Unlock()
+			return
+		}
+	}
+}
+
+// control sends an action to the worker, unless the job has already finished
+func (rj *remoteJob) control(action string) error {
+	select {
+	case <-rj.doneCh:
+		return nil
+	default:
+	}
+
+	resp, err := rj.transcoder.post(context.Background(), "/jobs/"+rj.id+"/"+action, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return readRemoteError(resp)
+	}
+	return nil
+}
+
+func (rj *remoteJob) Cancel()         { rj.control("cancel") }
+func (rj *remoteJob) Pause() error    { return rj.control("pause") }
+func (rj *remoteJob) Resume() error   { return rj.control("resume") }
+func (rj *remoteJob) Inspect() string { return rj.command }
+
+func (rj *remoteJob) Stop(grace time.Duration) {
+	rj.control("stop?grace=" + url.QueryEscape(grace.String()))
+}
+
+func (rj *remoteJob) Err() error {
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+	return rj.err
+}
+
+func (rj *remoteJob) Log() string                    { return rj.log.String() }
+func (rj *remoteJob) LogEntries() *LogIterator       { return rj.log.iterator() }
+func (rj *remoteJob) Progress() <-chan TranscodeInfo { return rj.progressCh }
+
+func (rj *remoteJob) Subscribe() (<-chan TranscodeInfo, func()) {
+	return rj.progress.subscribe()
+}
+
+func (rj *remoteJob) Wait() error {
+	<-rj.doneCh
+	return rj.Err()
+}
+
+func (rj *remoteJob) Result() TranscodeResult {
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+	return rj.result
+}
@@ -0,0 +1,408 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mh-orange/cmd"
+)
+
+var remoteSpec = JobSpec{
+	Inputs:  []InputSpec{{URL: "http://example.com/input.mkv"}},
+	Outputs: []OutputSpec{{Filename: "output.mp4", VideoCodec: "libx264"}},
+}
+
+func TestRemoteTranscoder(t *testing.T) {
+	stderr, err := ioutil.ReadFile("testdata/transcode2.txt")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	transcoder := NewTranscoder()
+	transcoder.Binaries = &Binaries{Ffmpeg: &cmd.TestCmd{Stderr: stderr}}
+	options, _ := remoteSpec.Options()
+	local, err := transcoder.Transcode(options...)
+	if err == nil {
+		err = local.Wait()
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	server := httptest.NewServer(NewWorker(transcoder))
+	defer server.Close()
+
+	job, err := NewRemoteTranscoder(server.URL).TranscodeSpec(remoteSpec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	updates, _ := job.Subscribe()
+	var info TranscodeInfo
+	for info = range updates {
+	}
+
+	if err := job.Wait(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if info.Frame != local.Result().Frames {
+		t.Errorf("wanted final frame %d got %d", local.Result().Frames, info.Frame)
+	}
+
+	if local.Inspect() != job.Inspect() {
+		t.Errorf("wanted command %q got %q", local.Inspect(), job.Inspect())
+	}
+
+	if local.Log() != job.Log() {
+		t.Errorf("wanted log %q got %q", local.Log(), job.Log())
+	}
+
+	want, got := local.Result(), job.Result()
+	want.Elapsed, got.Elapsed = 0, 0
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("wanted result %+v got %+v", want, got)
+	}
+}
+
+func TestRemoteTranscoderErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcoder *TestTranscoder
+		startErr   error
+		wantErr    error
+	}{
+		{"start error", &TestTranscoder{TranscodeErr: ErrFfmpegNotFound}, ErrFfmpegNotFound, nil},
+		{"unsupported", &TestTranscoder{TranscodeErr: &UnsupportedError{Kind: "encoder", Name: "libx265"}}, ErrUnsupported, nil},
+		{"transcode error", &TestTranscoder{JobErr: &TranscodeError{ExitCode: 1, Log: []string{"Unknown encoder 'libx265'"}, Cause: ErrUnknownEncoder}}, nil, ErrUnknownEncoder},
+		{"stopped", &TestTranscoder{JobErr: ErrStopped}, nil, ErrStopped},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(NewWorker(test.transcoder))
+			defer server.Close()
+
+			job, err := NewRemoteTranscoder(server.URL).TranscodeSpec(remoteSpec)
+			if !errors.Is(err, test.startErr) || (err == nil) != (test.startErr == nil) {
+				t.Fatalf("wanted %v got %v", test.startErr, err)
+			} else if err != nil {
+				if err.Error() != test.transcoder.TranscodeErr.Error() {
+					t.Errorf("wanted %q got %q", test.transcoder.TranscodeErr.Error(), err.Error())
+				}
+				return
+			}
+
+			err = job.Wait()
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("wanted %v got %v", test.wantErr, err)
+			}
+
+			if err.Error() != test.transcoder.JobErr.Error() {
+				t.Errorf("wanted %q got %q", test.transcoder.JobErr.Error(), err.Error())
+			}
+		})
+	}
+}
+
+func TestRemoteTranscoderControl(t *testing.T) {
+	sc := &signalCmd{Command: &cmd.TestCmd{}}
+	transcoder := NewTranscoder()
+	transcoder.Binaries = &Binaries{Ffmpeg: sc}
+	server := httptest.NewServer(NewWorker(transcoder))
+	defer server.Close()
+
+	job, err := NewRemoteTranscoder(server.URL).TranscodeSpec(remoteSpec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	<-job.Progress()
+
+	want := []os.Signal{os.Interrupt}
+	if sigStop != nil {
+		if err := job.Pause(); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		if err := job.Resume(); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		want = []os.Signal{sigStop, sigCont, os.Interrupt}
+	}
+
+	job.Stop(time.Second)
+	if err := job.Wait(); err != ErrStopped {
+		t.Errorf("wanted %v got %v", ErrStopped, err)
+	}
+
+	if !reflect.DeepEqual(want, sc.proc.received) {
+		t.Errorf("wanted %v got %v", want, sc.proc.received)
+	}
+
+	// controlling a finished job does nothing
+	if err := job.Pause(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestRemoteTranscoderContext(t *testing.T) {
+	sc := &signalCmd{Command: &cmd.TestCmd{}}
+	transcoder := NewTranscoder()
+	transcoder.Binaries = &Binaries{Ffmpeg: sc}
+	worker := NewWorker(transcoder)
+	server := httptest.NewServer(worker)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job, err := NewRemoteTranscoder(server.URL).TranscodeSpecContext(ctx, remoteSpec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	<-job.Progress()
+
+	cancel()
+	if err := job.Wait(); err != context.Canceled {
+		t.Errorf("wanted %v got %v", context.Canceled, err)
+	}
+
+	// the worker kills the job once the client goes away
+	select {
+	case <-sc.proc.killed:
+	case <-time.After(5 * time.Second):
+		t.Errorf("worker did not cancel the job")
+	}
+}
+
+func TestRemoteTranscoderOptions(t *testing.T) {
+	stderr, err := ioutil.ReadFile("testdata/transcode2.txt")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	transcoder := NewTranscoder()
+	transcoder.Binaries = &Binaries{Ffmpeg: &cmd.TestCmd{Stderr: stderr}}
+	options, _ := remoteSpec.Options()
+	subs, _ := url.Parse("http://example.com/subs.srt")
+	options = append([]TranscoderOption{Input(InputURL(subs), InputArgsOption("-sub_charenc", "latin1"))}, options...)
+	local, err := transcoder.Transcode(options...)
+	if err == nil {
+		err = local.Wait()
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	server := httptest.NewServer(NewWorker(transcoder))
+	defer server.Close()
+
+	// the RemoteTranscoder can back a RetryTranscoder (or a Queue)
+	job, err := NewRetryTranscoder(NewRemoteTranscoder(server.URL), 1).Transcode(options...)
+	if err == nil {
+		err = job.Wait()
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if local.Inspect() != job.Inspect() {
+		t.Errorf("wanted command %q got %q", local.Inspect(), job.Inspect())
+	}
+
+	_, err = NewRemoteTranscoder(server.URL).Transcode(append(options, LogOption(ioutil.Discard))...)
+	if !errors.Is(err, ErrNoSpec) {
+		t.Errorf("wanted %v got %v", ErrNoSpec, err)
+	}
+}
+
+// specTranscoder records the JobSpec of each job and calls the job's log handlers
+// before returning, like a transcoder that logs synchronously
+type specTranscoder struct {
+	TestTranscoder
+	mu   sync.Mutex
+	spec JobSpec
+}
+
+func (st *specTranscoder) Transcode(options ...TranscoderOption) (TranscodeJob, error) {
+	job := &transcodeJob{}
+	var specOptions []TranscoderOption
+	for _, option := range options {
+		if _, ok := option.(transcoderOptionFunc); ok {
+			option.process(job)
+		} else {
+			specOptions = append(specOptions, option)
+		}
+	}
+
+	for _, handler := range job.logHandlers {
+		handler(LogEntry{Message: "starting", Text: "starting"})
+	}
+
+	spec, err := optionsSpec(specOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	st.mu.Lock()
+	st.spec = spec
+	st.mu.Unlock()
+	return st.TestTranscoder.Transcode(options...)
+}
+
+func TestWorkerRoot(t *testing.T) {
+	root, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		inputs   []InputSpec
+		output   string
+		wantErr  error
+		wantSpec JobSpec
+	}{
+		{"relative", []InputSpec{{Filename: "in.mkv"}}, "out/out.mp4", nil, JobSpec{
+			Inputs:  []InputSpec{{Filename: filepath.Join(root, "in.mkv")}},
+			Outputs: []OutputSpec{{Filename: filepath.Join(root, "out", "out.mp4")}},
+		}},
+		{"absolute", []InputSpec{{URL: "http://example.com/in.mkv"}}, filepath.Join(root, "out.mp4"), nil, JobSpec{
+			Inputs:  []InputSpec{{URL: "http://example.com/in.mkv"}},
+			Outputs: []OutputSpec{{Filename: filepath.Join(root, "out.mp4")}},
+		}},
+		{"output outside", []InputSpec{{Filename: "in.mkv"}}, "../out.mp4", ErrPathNotAllowed, JobSpec{}},
+		{"absolute output outside", []InputSpec{{Filename: "in.mkv"}}, filepath.Join(root, "..", "out.mp4"), ErrPathNotAllowed, JobSpec{}},
+		{"input outside", []InputSpec{{Filename: "../../in.mkv"}}, "out.mp4", ErrPathNotAllowed, JobSpec{}},
+		{"file URL", []InputSpec{{URL: "file:///etc/passwd"}}, "out.mp4", ErrPathNotAllowed, JobSpec{}},
+		{"concat URL", []InputSpec{{URL: "concat:/etc/passwd"}}, "out.mp4", ErrPathNotAllowed, JobSpec{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transcoder := &specTranscoder{}
+			worker := NewWorker(transcoder)
+			worker.Root = "testdata"
+			server := httptest.NewServer(worker)
+			defer server.Close()
+
+			job, err := NewRemoteTranscoder(server.URL).TranscodeSpec(JobSpec{Inputs: test.inputs, Outputs: []OutputSpec{{Filename: test.output}}})
+			if err == nil {
+				err = job.Wait()
+			}
+
+			if !errors.Is(err, test.wantErr) || (err == nil) != (test.wantErr == nil) {
+				t.Fatalf("wanted %v got %v", test.wantErr, err)
+			}
+
+			transcoder.mu.Lock()
+			defer transcoder.mu.Unlock()
+			if err == nil && !reflect.DeepEqual(test.wantSpec, transcoder.spec) {
+				t.Errorf("wanted %+v got %+v", test.wantSpec, transcoder.spec)
+			}
+		})
+	}
+}
+
+func TestWorkerAuthorize(t *testing.T) {
+	worker := NewWorker(&specTranscoder{})
+	worker.Authorize = func(req *http.Request, spec *JobSpec) error {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			return ErrPermissionDenied
+		}
+		return nil
+	}
+	server := httptest.NewServer(worker)
+	defer server.Close()
+
+	_, err := NewRemoteTranscoder(server.URL).TranscodeSpec(remoteSpec)
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("wanted %v got %v", ErrPermissionDenied, err)
+	}
+}
+
+func TestWorkerLogHandler(t *testing.T) {
+	server := httptest.NewServer(NewWorker(&specTranscoder{}))
+	defer server.Close()
+
+	job, err := NewRemoteTranscoder(server.URL).TranscodeSpec(remoteSpec)
+	if err == nil {
+		err = job.Wait()
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// the line logged before Transcode returned arrives after the started event
+	if log := job.Log(); !strings.Contains(log, "starting") {
+		t.Errorf("wanted log %q got %q", "starting", log)
+	}
+}
+
+// headerTransport adds a header to every request
+type headerTransport struct {
+	key, value string
+}
+
+func (ht *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(ht.key, ht.value)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWorkerAuthorizeControl(t *testing.T) {
+	sc := &signalCmd{Command: &cmd.TestCmd{}}
+	transcoder := NewTranscoder()
+	transcoder.Binaries = &Binaries{Ffmpeg: sc}
+	worker := NewWorker(transcoder)
+	worker.Authorize = func(req *http.Request, spec *JobSpec) error {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			return ErrPermissionDenied
+		}
+		return nil
+	}
+	server := httptest.NewServer(worker)
+	defer server.Close()
+
+	rt := NewRemoteTranscoder(server.URL)
+	rt.Client = &http.Client{Transport: &headerTransport{"Authorization", "Bearer secret"}}
+	job, err := rt.TranscodeSpec(remoteSpec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	<-job.Progress()
+
+	id := job.(*remoteJob).id
+	if len(id) != 32 {
+		t.Errorf("wanted a random job ID got %q", id)
+	}
+
+	resp, err := http.Post(server.URL+"/jobs/"+id+"/cancel", "application/json", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("wanted status %d got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	// the client that submitted the job can still control it
+	job.Stop(time.Second)
+	if err := job.Wait(); err != ErrStopped {
+		t.Errorf("wanted %v got %v", ErrStopped, err)
+	}
+}
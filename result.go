@@ -0,0 +1,107 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var finalStatsValuePtrn = regexp.MustCompile(`(video|audio|subtitle|other streams|global headers):\s*([0-9.]+)([a-zA-Z]*)`)
+
+// TranscodeResult is the summary of what a transcode job produced.  It is available
+// from TranscodeJob.Result once TranscodeJob.Wait returns
+type TranscodeResult struct {
+	// VideoSize is the number of bytes of video written to the output
+	VideoSize int64
+
+	// AudioSize is the number of bytes of audio written to the output
+	AudioSize int64
+
+	// SubtitleSize is the number of bytes of subtitles written to the output
+	SubtitleSize int64
+
+	// OtherSize is the number of bytes of other streams (data, attachments) written to the output
+	OtherSize int64
+
+	// GlobalHeadersSize is the number of bytes of global (codec) headers written to the output
+	GlobalHeadersSize int64
+
+	// MuxingOverhead is the container overhead as a percentage of the stream data
+	MuxingOverhead float64
+
+	// Frames is the final number of frames processed
+	Frames int
+
+	// Time is the final output position
+	Time Time
+
+	// Elapsed is the wall clock time the ffmpeg process ran for
+	Elapsed time.Duration
+
+	// Speed is the average processing speed relative to real time
+	Speed float64
+
+	// Anomalies are the warnings ffmpeg logged that suggest the output may be
+	// damaged.  These are collected even when ffmpeg exits successfully
+	Anomalies []Anomaly
+}
+
+// parse reads the final statistics line that ffmpeg prints after the output is
+// closed, for instance:
+//
+//	video:1167kB audio:155kB subtitle:0kB other streams:0kB global headers:0kB muxing overhead: 1.278846%
+func (tr *TranscodeResult) parse(line string) (err error) {
+	for _, match := range finalStatsValuePtrn.FindAllStringSubmatch(line, -1) {
+		var size int64
+		size, err = parseSize(match[2], match[3])
+		if err != nil {
+			break
+		}
+
+		switch match[1] {
+		case "video":
+			tr.VideoSize = size
+		case "audio":
+			tr.AudioSize = size
+		case "subtitle":
+			tr.SubtitleSize = size
+		case "other streams":
+			tr.OtherSize = size
+		case "global headers":
+			tr.GlobalHeadersSize = size
+		}
+	}
+
+	substr := "muxing overhead:"
+	if index := strings.Index(line, substr); index >= 0 && err == nil {
+		overhead := strings.TrimSuffix(strings.TrimSpace(line[index+len(substr):]), "%")
+		if overhead != "unknown" {
+			tr.MuxingOverhead, err = strconv.ParseFloat(overhead, 64)
+		}
+	}
+	return err
+}
+
+// parseSize converts the sizes ffmpeg reports (which use binary multiples even
+// when labeled kB) into bytes
+func parseSize(value, unit string) (int64, error) {
+	size, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	switch strings.ToLower(unit) {
+	case "", "b":
+	case "kb", "kib":
+		size *= 1 << 10
+	case "mb", "mib":
+		size *= 1 << 20
+	case "gb", "gib":
+		size *= 1 << 30
+	default:
+		return 0, fmt.Errorf("unknown size unit %q", unit)
+	}
+	return int64(size), nil
+}
@@ -0,0 +1,65 @@
+package ffmpeg
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/mh-orange/cmd"
+)
+
+func TestTranscodeResultParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    TranscodeResult
+		wantErr bool
+	}{
+		{"video:1167kB audio:155kB subtitle:0kB other streams:0kB global headers:0kB muxing overhead: 1.5%", TranscodeResult{VideoSize: 1167 * 1024, AudioSize: 155 * 1024, MuxingOverhead: 1.5}, false},
+		{"[out#0/mp4 @ 0x5581] video:2KiB audio:1KiB subtitle:1KiB other streams:0KiB global headers:1KiB muxing overhead: unknown", TranscodeResult{VideoSize: 2048, AudioSize: 1024, SubtitleSize: 1024, GlobalHeadersSize: 1024}, false},
+		{"video:1MiB audio:0kB subtitle:0kB other streams:2kB global headers:0kB muxing overhead: 0.000000%", TranscodeResult{VideoSize: 1 << 20, OtherSize: 2048}, false},
+		{"video:1XB audio:0kB subtitle:0kB other streams:0kB global headers:0kB muxing overhead: 0%", TranscodeResult{}, true},
+		{"video:1kB audio:0kB subtitle:0kB other streams:0kB global headers:0kB muxing overhead: lots", TranscodeResult{VideoSize: 1024}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := TranscodeResult{}
+			err := got.parse(test.input)
+			if err != nil && !test.wantErr {
+				t.Errorf("Unexpected error: %v", err)
+			} else if err == nil && test.wantErr {
+				t.Errorf("wanted error got nil")
+			} else if err == nil && !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want %+v got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestTranscodeJobResult(t *testing.T) {
+	oldFfmpeg := Ffmpeg
+	input, err := ioutil.ReadFile("testdata/transcode1.txt")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	Ffmpeg = &cmd.TestCmd{Stderr: input}
+
+	job, err := NewTranscoder().Transcode()
+	if err == nil {
+		err = job.Wait()
+	}
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got := job.Result()
+	if got.VideoSize != 1657*1024 || got.AudioSize != 157*1024 || got.MuxingOverhead != 0.365 {
+		t.Errorf("unexpected sizes %+v", got)
+	}
+
+	if got.Frames != 240 || got.Time != 10010*Millisecond || got.Speed != 2.51 {
+		t.Errorf("unexpected final progress %+v", got)
+	}
+	Ffmpeg = oldFfmpeg
+}
@@ -0,0 +1,299 @@
+package ffmpeg
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Remedy inspects the error of a failed transcode job.  If the Remedy knows how to fix
+// the failure then it returns the options to use for the next attempt and true
+type Remedy func(err error, options []TranscoderOption) ([]TranscoderOption, bool)
+
+// GenPTSRemedy regenerates presentation timestamps (-fflags +genpts on every input)
+// when the muxer rejects packets with non monotonically increasing timestamps
+func GenPTSRemedy() Remedy {
+	return func(err error, options []TranscoderOption) ([]TranscoderOption, bool) {
+		if errors.Is(err, ErrNonMonotonicDTS) {
+			return withInputOptions(options, InputArgsOption("-fflags", "+genpts")), true
+		}
+		return nil, false
+	}
+}
+
+// ReencodeRemedy re-encodes streams that were being copied when the output container
+// cannot hold the copied codec.  The default encoders for the output format are used
+func ReencodeRemedy() Remedy {
+	return func(err error, options []TranscoderOption) ([]TranscoderOption, bool) {
+		if errors.Is(err, ErrUnsupportedCodec) {
+			return withOutputOptions(options, func(output *output) error {
+				if output.vCodec == "copy" {
+					output.vCodec = ""
+				}
+
+				if output.aCodec == "copy" {
+					output.aCodec = ""
+				}
+
+				if output.sCodec == "copy" {
+					output.sCodec = ""
+				}
+				return nil
+			}), true
+		}
+		return nil, false
+	}
+}
+
+// MuxingQueueRemedy sets -max_muxing_queue_size on every output when ffmpeg reports
+// that too many packets were buffered waiting for another stream
+func MuxingQueueRemedy(size int) Remedy {
+	return func(err error, options []TranscoderOption) ([]TranscoderOption, bool) {
+		if errors.Is(err, ErrMuxingQueueFull) {
+			return withOutputOptions(options, OutputArgsOption("-max_muxing_queue_size", strconv.Itoa(size))), true
+		}
+		return nil, false
+	}
+}
+
+// DefaultRemedies returns every remedy in this package
+func DefaultRemedies() []Remedy {
+	return []Remedy{GenPTSRemedy(), ReencodeRemedy(), MuxingQueueRemedy(9999)}
+}
+
+func withInputOptions(options []TranscoderOption, extra ...InputOption) []TranscoderOption {
+	updated := make([]TranscoderOption, len(options))
+	for i, option := range options {
+		if in, ok := option.(*input); ok {
+			option = in.clone(extra...)
+		}
+		updated[i] = option
+	}
+	return updated
+}
+
+func withOutputOptions(options []TranscoderOption, extra ...OutputOption) []TranscoderOption {
+	updated := make([]TranscoderOption, len(options))
+	for i, option := range options {
+		if out, ok := option.(*output); ok {
+			option = out.clone(extra...)
+		}
+		updated[i] = option
+	}
+	return updated
+}
+
+// heldWriter is a writer whose Close does nothing, so that it can be used by more
+// than one attempt
+type heldWriter struct {
+	io.Writer
+}
+
+func (heldWriter) Close() error { return nil }
+
+// holdStderr replaces the writers of StderrOptions with heldWriters, so that they are
+// not closed when an attempt exits.  The original writers are returned so they can be
+// closed once the final attempt has finished
+func holdStderr(options []TranscoderOption) ([]TranscoderOption, []io.Closer) {
+	updated := make([]TranscoderOption, len(options))
+	var closers []io.Closer
+	for i, option := range options {
+		if so, ok := option.(stderrOption); ok {
+			closers = append(closers, so.writer)
+			option = stderrOption{heldWriter{so.writer}}
+		}
+		updated[i] = option
+	}
+	return updated, closers
+}
+
+// retryable indicates if the options can be used for more than one attempt.  Inputs
+// read from STDIN and outputs written to STDOUT can only be used once
+func retryable(options []TranscoderOption) bool {
+	for _, option := range options {
+		if in, ok := option.(*input); ok && in.stdin() {
+			return false
+		} else if out, ok := option.(*output); ok && out.writer != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// RetryTranscoder wraps a transcoder and, when a job fails, retries it with the
+// first Remedy that knows how to fix the failure.  Each Remedy is used at most once
+// per job
+type RetryTranscoder struct {
+	transcoder  JobTranscoder
+	maxAttempts int
+	remedies    []Remedy
+}
+
+// NewRetryTranscoder returns a RetryTranscoder that will run each job at most
+// maxAttempts times.  If no remedies are given then DefaultRemedies are used
+func NewRetryTranscoder(transcoder JobTranscoder, maxAttempts int, remedies ...Remedy) *RetryTranscoder {
+	if len(remedies) == 0 {
+		remedies = DefaultRemedies()
+	}
+	return &RetryTranscoder{transcoder: transcoder, maxAttempts: maxAttempts, remedies: remedies}
+}
+
+// Transcode starts the first attempt and returns a TranscodeJob that represents all
+// the attempts.  Progress subscribers see the updates of each attempt in turn and
+// Wait returns the error of the final attempt.  Writers passed to StderrOption receive
+// the output of every attempt and are closed after the final one
+func (rt *RetryTranscoder) Transcode(options ...TranscoderOption) (TranscodeJob, error) {
+	options, closers := holdStderr(options)
+	job, err := rt.transcoder.Transcode(options...)
+	if err != nil {
+		return job, err
+	}
+
+	rj := &retryJob{
+		current:  job,
+		progress: newProgressBroadcaster(),
+		doneCh:   make(chan struct{}),
+	}
+	rj.progressCh, _ = rj.progress.subscribe()
+	go rj.run(rt, options, closers)
+	return rj, nil
+}
+
+// retryJob is the TranscodeJob returned by RetryTranscoder.  Methods other than
+// Wait and Cancel are forwarded to the current attempt
+type retryJob struct {
+	mu       sync.Mutex
+	current  TranscodeJob
+	attempts int
+	canceled bool
+	paused   bool
+	err      error
+
+	progress   *progressBroadcaster
+	progressCh <-chan TranscodeInfo
+	doneCh     chan struct{}
+}
+
+func (rj *retryJob) run(rt *RetryTranscoder, options []TranscoderOption, closers []io.Closer) {
+	defer close(rj.doneCh)
+	defer rj.progress.close()
+	defer func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}()
+
+	used := make([]bool, len(rt.remedies))
+	for job := rj.current; ; {
+		updates, _ := job.Subscribe()
+		for info := range updates {
+			rj.progress.publish(info)
+		}
+
+		err := job.Wait()
+		rj.mu.Lock()
+		rj.attempts++
+		rj.err = err
+		stop := err == nil || rj.canceled || rj.attempts >= rt.maxAttempts || !retryable(options)
+		rj.mu.Unlock()
+		if stop {
+			return
+		}
+
+		remedied := false
+		for i, remedy := range rt.remedies {
+			if used[i] {
+				continue
+			}
+
+			if next, ok := remedy(err, options); ok {
+				used[i] = true
+				options = next
+				remedied = true
+				break
+			}
+		}
+
+		if !remedied {
+			return
+		}
+
+		job, err = rt.transcoder.Transcode(options...)
+		rj.mu.Lock()
+		if err != nil {
+			rj.err = err
+		} else {
+			rj.current = job
+			if rj.canceled {
+				job.Cancel()
+			} else if rj.paused {
+				job.Pause()
+			}
+		}
+		rj.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// job returns the current attempt
+func (rj *retryJob) job() TranscodeJob {
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+	return rj.current
+}
+
+func (rj *retryJob) Cancel() {
+	rj.mu.Lock()
+	rj.canceled = true
+	job := rj.current
+	rj.mu.Unlock()
+	job.Cancel()
+}
+
+func (rj *retryJob) Stop(grace time.Duration) {
+	rj.mu.Lock()
+	rj.canceled = true
+	job := rj.current
+	rj.mu.Unlock()
+	job.Stop(grace)
+}
+
+func (rj *retryJob) Pause() error {
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+	rj.paused = true
+	return rj.current.Pause()
+}
+
+func (rj *retryJob) Resume() error {
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+	rj.paused = false
+	return rj.current.Resume()
+}
+
+func (rj *retryJob) Err() error {
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+	return rj.err
+}
+
+func (rj *retryJob) Log() string                    { return rj.job().Log() }
+func (rj *retryJob) LogEntries() *LogIterator       { return rj.job().LogEntries() }
+func (rj *retryJob) Progress() <-chan TranscodeInfo { return rj.progressCh }
+func (rj *retryJob) Inspect() string                { return rj.job().Inspect() }
+func (rj *retryJob) Result() TranscodeResult        { return rj.job().Result() }
+
+func (rj *retryJob) Subscribe() (<-chan TranscodeInfo, func()) {
+	return rj.progress.subscribe()
+}
+
+func (rj *retryJob) Wait() error {
+	<-rj.doneCh
+	return rj.Err()
+}
@@ -0,0 +1,178 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mh-orange/cmd"
+)
+
+// scriptedTranscoder returns jobs that fail with each of the errors in turn
+type scriptedTranscoder struct {
+	errs []error
+	args [][]string
+}
+
+func (st *scriptedTranscoder) Transcode(options ...TranscoderOption) (TranscodeJob, error) {
+	job := &transcodeJob{proc: (&cmd.TestCmd{}).Process()}
+	for _, option := range options {
+		option.process(job)
+	}
+	st.args = append(st.args, job.proc.Args())
+
+	var err error
+	if len(st.errs) > 0 {
+		err, st.errs = st.errs[0], st.errs[1:]
+	}
+	return &TestJob{err: err}, nil
+}
+
+func causeErr(cause error) error {
+	return &TranscodeError{Cause: cause}
+}
+
+func TestRetryTranscoder(t *testing.T) {
+	u, _ := url.Parse("http://video.net/foo.ts")
+	options := func() []TranscoderOption {
+		return []TranscoderOption{Input(InputURL(u)), Output(OutputFilename("foo.mp4"), CopyOutput())}
+	}
+
+	tests := []struct {
+		name         string
+		errs         []error
+		maxAttempts  int
+		wantErr      error
+		wantAttempts int
+		wantArgs     string
+	}{
+		{"success", nil, 3, nil, 1, "-c:v copy -c:a copy -y foo.mp4"},
+		{"genpts", []error{causeErr(ErrNonMonotonicDTS)}, 3, nil, 2, "-fflags +genpts -i http://video.net/foo.ts -c:v copy"},
+		{"reencode", []error{causeErr(ErrUnsupportedCodec)}, 3, nil, 2, "-i http://video.net/foo.ts -y foo.mp4"},
+		{"muxing queue", []error{causeErr(ErrMuxingQueueFull)}, 3, nil, 2, "-max_muxing_queue_size 9999 -y foo.mp4"},
+		{"remedies combined", []error{causeErr(ErrNonMonotonicDTS), causeErr(ErrMuxingQueueFull)}, 3, nil, 3, "-fflags +genpts -i http://video.net/foo.ts -c:v copy -c:a copy -max_muxing_queue_size 9999 -y foo.mp4"},
+		{"remedy used once", []error{causeErr(ErrNonMonotonicDTS), causeErr(ErrNonMonotonicDTS)}, 3, ErrNonMonotonicDTS, 2, "-fflags +genpts -i"},
+		{"max attempts", []error{causeErr(ErrNonMonotonicDTS), causeErr(ErrMuxingQueueFull)}, 2, ErrMuxingQueueFull, 2, "-fflags +genpts"},
+		{"unknown cause", []error{causeErr(ErrInvalidData)}, 3, ErrInvalidData, 1, "-i http://video.net/foo.ts"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transcoder := &scriptedTranscoder{errs: test.errs}
+			job, err := NewRetryTranscoder(transcoder, test.maxAttempts).Transcode(options()...)
+			if err == nil {
+				err = job.Wait()
+			}
+
+			if !errors.Is(err, test.wantErr) || (test.wantErr == nil && err != nil) {
+				t.Errorf("want %v got %v", test.wantErr, err)
+			}
+
+			if len(transcoder.args) != test.wantAttempts {
+				t.Fatalf("want %d attempts got %d", test.wantAttempts, len(transcoder.args))
+			}
+
+			last := strings.Join(transcoder.args[len(transcoder.args)-1], " ")
+			if !strings.Contains(last, test.wantArgs) {
+				t.Errorf("want %q in %q", test.wantArgs, last)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		options []TranscoderOption
+		want    bool
+	}{
+		{[]TranscoderOption{Input(InputReader(strings.NewReader("")))}, false},
+		{[]TranscoderOption{Output(OutputWriter(&strings.Builder{}))}, false},
+		{[]TranscoderOption{Input(InputURL(&url.URL{})), Output(OutputFilename("foo.mkv"))}, true},
+	}
+
+	for i, test := range tests {
+		for _, option := range test.options {
+			if in, ok := option.(*input); ok {
+				for _, o := range in.options {
+					o(in)
+				}
+			} else if out, ok := option.(*output); ok {
+				for _, o := range out.options {
+					o(out)
+				}
+			}
+		}
+
+		if got := retryable(test.options); got != test.want {
+			t.Errorf("tests[%d] want %v got %v", i, test.want, got)
+		}
+	}
+}
+
+func TestWithInputOptions(t *testing.T) {
+	original := Input(InputURL(&url.URL{Scheme: "file", Path: "/foo"})).input()
+	options := withInputOptions([]TranscoderOption{original}, InputArgsOption("-re"))
+	job := &transcodeJob{proc: (&cmd.TestCmd{}).Process()}
+	options[0].process(job)
+	original.process(job)
+
+	if want := []string{"-re", "-i", "file:///foo"}; !reflect.DeepEqual(want, options[0].(*input).args) {
+		t.Errorf("want %v got %v", want, options[0].(*input).args)
+	}
+
+	if want := []string{"-i", "file:///foo"}; !reflect.DeepEqual(want, original.args) {
+		t.Errorf("expected original input to be unchanged, got %v", original.args)
+	}
+}
+
+// closingBuffer is a WriteCloser that fails writes once it has been closed
+type closingBuffer struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closes int
+}
+
+func (cb *closingBuffer) Write(p []byte) (int, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.closes > 0 {
+		return 0, io.ErrClosedPipe
+	}
+	return cb.buf.Write(p)
+}
+
+func (cb *closingBuffer) Close() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.closes++
+	return nil
+}
+
+func TestRetryTranscoderStderr(t *testing.T) {
+	transcoder := NewTranscoder()
+	transcoder.Binaries = helperBinaries(t, "fail")
+	stderr := &closingBuffer{}
+	job, err := NewRetryTranscoder(transcoder, 2, MuxingQueueRemedy(9999)).Transcode(StderrOption(stderr), Output(OutputFilename("foo.mp4")))
+	if err == nil {
+		err = job.Wait()
+	}
+
+	if !errors.Is(err, ErrMuxingQueueFull) {
+		t.Errorf("want %v got %v", ErrMuxingQueueFull, err)
+	}
+
+	stderr.mu.Lock()
+	defer stderr.mu.Unlock()
+	if got := strings.Count(stderr.buf.String(), "Too many packets"); got != 2 {
+		t.Errorf("want the output of 2 attempts got %d", got)
+	}
+
+	if stderr.closes != 1 {
+		t.Errorf("want writer closed once got %d", stderr.closes)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package ffmpeg
+
+import (
+	"os"
+	"syscall"
+)
+
+// signals used to pause and resume the ffmpeg process
+var (
+	sigStop os.Signal = syscall.SIGSTOP
+	sigCont os.Signal = syscall.SIGCONT
+)
@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package ffmpeg
+
+import "os"
+
+// processes cannot be paused and resumed on windows
+var (
+	sigStop os.Signal
+	sigCont os.Signal
+)
@@ -0,0 +1,196 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// ErrNoSpec is returned when options cannot be described by a JobSpec, and so cannot be
+// stored or sent to a remote worker
+var ErrNoSpec = errors.New("option cannot be described by a job spec")
+
+// JobSpec is a description of a transcode job that, unlike a list of TranscoderOptions,
+// can be encoded as JSON.  This allows jobs to be stored and sent to other processes
+type JobSpec struct {
+	// Inputs are the inputs to the job, in order
+	Inputs []InputSpec `json:"inputs"`
+
+	// Maps are the stream specifiers passed to ffmpeg with -map (for instance "0:v:0")
+	Maps []string `json:"maps,omitempty"`
+
+	// VideoFilter is the filter chain passed to ffmpeg (see VideoFilterOption)
+	VideoFilter string `json:"videoFilter,omitempty"`
+
+	// Outputs are the outputs of the job, in order
+	Outputs []OutputSpec `json:"outputs"`
+}
+
+// InputSpec describes a single input of a JobSpec.  Either Filename or URL should be set
+type InputSpec struct {
+	// Filename is the name of a local file that is probed and passed to ffmpeg
+	Filename string `json:"filename,omitempty"`
+
+	// URL is passed to ffmpeg without being probed
+	URL string `json:"url,omitempty"`
+
+	// Start is the position ffmpeg seeks to before processing the input (see StartOption)
+	Start Time `json:"start,omitempty"`
+
+	// Duration limits how much of the input is processed (see DurationOption)
+	Duration Time `json:"duration,omitempty"`
+
+	// Args are additional arguments placed before the input (see InputArgsOption)
+	Args []string `json:"args,omitempty"`
+}
+
+// OutputSpec describes a single output file of a JobSpec
+type OutputSpec struct {
+	// Filename is the name of the file that is written
+	Filename string `json:"filename"`
+
+	// Format is the output container format (see OutputFormat)
+	Format string `json:"format,omitempty"`
+
+	// FormatOptions are additional arguments passed after the format
+	FormatOptions []string `json:"formatOptions,omitempty"`
+
+	// VideoCodec is the video encoder, or "copy"
+	VideoCodec string `json:"videoCodec,omitempty"`
+
+	// VideoCodecOptions are additional arguments passed after the video codec
+	VideoCodecOptions []string `json:"videoCodecOptions,omitempty"`
+
+	// PixFmt is the output pixel format
+	PixFmt string `json:"pixFmt,omitempty"`
+
+	// AudioCodec is the audio encoder, or "copy"
+	AudioCodec string `json:"audioCodec,omitempty"`
+
+	// AudioCodecOptions are additional arguments passed after the audio codec
+	AudioCodecOptions []string `json:"audioCodecOptions,omitempty"`
+
+	// SubtitleCodec is the subtitle encoder, or "copy"
+	SubtitleCodec string `json:"subtitleCodec,omitempty"`
+
+	// Args are additional arguments placed before the filename (see OutputArgsOption)
+	Args []string `json:"args,omitempty"`
+}
+
+// Options converts the JobSpec into the TranscoderOptions that can be passed to
+// Transcoder.Transcode
+func (spec *JobSpec) Options() (options []TranscoderOption, err error) {
+	for _, is := range spec.Inputs {
+		inputOptions := []InputOption{}
+		if is.Filename != "" {
+			inputOptions = append(inputOptions, InputFilename(is.Filename))
+		} else if is.URL != "" {
+			var u *url.URL
+			u, err = url.Parse(is.URL)
+			if err != nil {
+				return nil, err
+			}
+			inputOptions = append(inputOptions, InputURL(u))
+		}
+
+		if is.Start != 0 {
+			inputOptions = append(inputOptions, StartOption(is.Start))
+		}
+
+		if is.Duration != 0 {
+			inputOptions = append(inputOptions, DurationOption(is.Duration))
+		}
+
+		if len(is.Args) > 0 {
+			inputOptions = append(inputOptions, InputArgsOption(is.Args...))
+		}
+		options = append(options, Input(inputOptions...))
+	}
+
+	for _, m := range spec.Maps {
+		options = append(options, mapSpecifierOption(m))
+	}
+
+	if spec.VideoFilter != "" {
+		options = append(options, VideoFilterOption(spec.VideoFilter))
+	}
+
+	for _, out := range spec.Outputs {
+		var outputOptions []OutputOption
+		if len(out.Args) > 0 {
+			outputOptions = append(outputOptions, OutputArgsOption(out.Args...))
+		}
+
+		options = append(options, &output{
+			filename:      out.Filename,
+			format:        out.Format,
+			formatOptions: out.FormatOptions,
+			vCodec:        out.VideoCodec,
+			vCodecOptions: out.VideoCodecOptions,
+			pix_fmt:       out.PixFmt,
+			aCodec:        out.AudioCodec,
+			aCodecOptions: out.AudioCodecOptions,
+			sCodec:        out.SubtitleCodec,
+			options:       outputOptions,
+		})
+	}
+	return options, nil
+}
+
+// Filenames returns the names of all the files the job writes
+func (spec *JobSpec) Filenames() (filenames []string) {
+	for _, out := range spec.Outputs {
+		if out.Filename != "" {
+			filenames = append(filenames, out.Filename)
+		}
+	}
+	return filenames
+}
+
+// mapSpecifierOption passes a stream specifier to ffmpeg with -map
+type mapSpecifierOption string
+
+func (mso mapSpecifierOption) process(job *transcodeJob) error {
+	job.proc.AppendArgs("-map", string(mso))
+	return nil
+}
+
+// optionsSpec describes the options as a JobSpec, the reverse of JobSpec.Options.  Inputs,
+// outputs, maps and video filters can be described, along with the changes that the
+// remedies of a RetryTranscoder make to them.  Any other option, an input read from STDIN
+// or an output written to STDOUT results in an error wrapping ErrNoSpec
+func optionsSpec(options []TranscoderOption) (spec JobSpec, err error) {
+	for _, option := range options {
+		switch o := option.(type) {
+		case *input:
+			var is InputSpec
+			if is, err = o.spec(); err == nil {
+				spec.Inputs = append(spec.Inputs, is)
+			}
+		case *output:
+			var out OutputSpec
+			if out, err = o.spec(); err == nil {
+				spec.Outputs = append(spec.Outputs, out)
+			}
+		case mapSpecifierOption:
+			spec.Maps = append(spec.Maps, string(o))
+		case videoFilterOption:
+			if spec.VideoFilter != "" {
+				err = fmt.Errorf("%w: more than one video filter", ErrNoSpec)
+			}
+			spec.VideoFilter = string(o)
+		default:
+			err = fmt.Errorf("%w: %T", ErrNoSpec, option)
+		}
+
+		if err != nil {
+			return JobSpec{}, err
+		}
+
+		// JobSpec.Options puts maps and the filter before all the outputs
+		if _, isOutput := option.(*output); !isOutput && len(spec.Outputs) > 0 {
+			return JobSpec{}, fmt.Errorf("%w: %T after an output", ErrNoSpec, option)
+		}
+	}
+	return spec, nil
+}
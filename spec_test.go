@@ -0,0 +1,115 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mh-orange/cmd"
+)
+
+func TestJobSpecOptions(t *testing.T) {
+	input := []byte(`{
+		"inputs": [{"url": "http://video.net/foo", "start": "00:01:00.000000", "duration": "00:00:30.000000"}],
+		"maps": ["0:p:3"],
+		"videoFilter": "yadif",
+		"outputs": [{"filename": "foo.mkv", "format": "matroska", "videoCodec": "libx264", "videoCodecOptions": ["-crf", "20"], "audioCodec": "copy"}]
+	}`)
+
+	spec := JobSpec{}
+	err := json.Unmarshal(input, &spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	options, err := spec.Options()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	job := &transcodeJob{proc: (&cmd.TestCmd{}).Process()}
+	for _, option := range options {
+		if err := option.process(job); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	want := []string{"-ss", "00:01:00.000000", "-t", "00:00:30.000000", "-i", "http://video.net/foo", "-map", "0:p:3", "-lavfi", "yadif", "-c:v", "libx264", "-crf", "20", "-c:a", "copy", "-f", "matroska", "-y", "foo.mkv"}
+	got := job.proc.Args()
+	if len(got) < len(want) || !reflect.DeepEqual(want, got[len(got)-len(want):]) {
+		t.Errorf("want %v got %v", want, got)
+	}
+
+	if filenames := spec.Filenames(); !reflect.DeepEqual([]string{"foo.mkv"}, filenames) {
+		t.Errorf("want [foo.mkv] got %v", filenames)
+	}
+
+	spec.Inputs[0].URL = "://"
+	if _, err := spec.Options(); err == nil {
+		t.Errorf("want error for invalid URL")
+	}
+}
+
+func TestOptionsSpec(t *testing.T) {
+	spec := JobSpec{
+		Inputs:      []InputSpec{{URL: "http://video.net/foo", Start: Minute, Args: []string{"-fflags", "+genpts"}}, {Filename: "bar.mkv"}},
+		Maps:        []string{"0:p:3", "1:a"},
+		VideoFilter: "yadif",
+		Outputs:     []OutputSpec{{Filename: "foo.mkv", Format: "matroska", VideoCodec: "libx264", VideoCodecOptions: []string{"-crf", "20"}, AudioCodec: "copy", Args: []string{"-max_muxing_queue_size", "1024"}}},
+	}
+
+	options, err := spec.Options()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got, err := optionsSpec(options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(spec, got) {
+		t.Errorf("want %+v got %+v", spec, got)
+	}
+
+	u, _ := url.Parse("http://video.net/foo")
+	got, err = optionsSpec([]TranscoderOption{
+		Input(InputURL(u)),
+		MapProgramOption(0, 3),
+		Output(OutputFilename("foo.mkv"), CopyAudioOption(), OutputArgsOption("-shortest")),
+	})
+	want := JobSpec{
+		Inputs:  []InputSpec{{URL: "http://video.net/foo"}},
+		Maps:    []string{"0:p:3"},
+		Outputs: []OutputSpec{{Filename: "foo.mkv", AudioCodec: "copy", Args: []string{"-shortest"}}},
+	}
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+
+	tests := []struct {
+		name    string
+		options []TranscoderOption
+	}{
+		{"input reader", []TranscoderOption{Input(InputReader(bytes.NewReader(nil)))}},
+		{"input file", []TranscoderOption{Input(InputFile(os.Stdin))}},
+		{"output writer", []TranscoderOption{Output(OutputWriter(&bytes.Buffer{}))}},
+		{"log option", []TranscoderOption{LogOption(&bytes.Buffer{})}},
+		{"map after output", []TranscoderOption{Output(OutputFilename("foo.mkv")), MapOption(0)}},
+		{"two filters", []TranscoderOption{VideoFilterOption("yadif"), VideoFilterOption("scale=640:-1")}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := optionsSpec(test.options); !errors.Is(err, ErrNoSpec) {
+				t.Errorf("wanted %v got %v", ErrNoSpec, err)
+			}
+		})
+	}
+}
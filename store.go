@@ -0,0 +1,130 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// JobRecord is the persisted form of a QueuedJob
+type JobRecord struct {
+	// ID is the QueuedJob ID
+	ID string `json:"id"`
+
+	// Tenant is the QueuedJob Tenant
+	Tenant string `json:"tenant,omitempty"`
+
+	// Priority is the QueuedJob Priority
+	Priority int `json:"priority,omitempty"`
+
+	// Spec is the QueuedJob Spec
+	Spec JobSpec `json:"spec"`
+
+	// State is the last known state of the job
+	State JobState `json:"state"`
+
+	// Error is the error message of a failed job
+	Error string `json:"error,omitempty"`
+}
+
+// JobStore persists the jobs of a Queue so they survive a restart of the process
+type JobStore interface {
+	// Save creates or replaces the record with the same ID
+	Save(record JobRecord) error
+
+	// Delete removes the record with the given ID
+	Delete(id string) error
+
+	// Load returns every saved record
+	Load() ([]JobRecord, error)
+}
+
+// FileJobStore is a JobStore that keeps each job record in its own JSON file
+type FileJobStore struct {
+	dir string
+}
+
+// NewFileJobStore returns a FileJobStore that keeps its files in dir.  The
+// directory is created if it does not exist
+func NewFileJobStore(dir string) (*FileJobStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileJobStore{dir: dir}, nil
+}
+
+func (fjs *FileJobStore) filename(id string) string {
+	return filepath.Join(fjs.dir, id+".json")
+}
+
+// Save writes the record to a temporary file and renames it into place so that
+// a crash never leaves a partially written record
+func (fjs *FileJobStore) Save(record JobRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(fjs.dir, record.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(file.Name(), fjs.filename(record.ID))
+	}
+
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// Delete removes the file for the record, it is not an error if the file does not exist
+func (fjs *FileJobStore) Delete(id string) error {
+	err := os.Remove(fjs.filename(id))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
+
+// Load reads every record file in the directory
+func (fjs *FileJobStore) Load() (records []JobRecord, err error) {
+	infos, err := ioutil.ReadDir(fjs.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".json") {
+			continue
+		}
+
+		var data []byte
+		data, err = ioutil.ReadFile(filepath.Join(fjs.dir, info.Name()))
+		if err != nil {
+			break
+		}
+
+		record := JobRecord{}
+		err = json.Unmarshal(data, &record)
+		if err != nil {
+			break
+		}
+		records = append(records, record)
+	}
+	return records, err
+}
@@ -0,0 +1,170 @@
+package ffmpeg
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestFileJobStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ffmpeg-store")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileJobStore(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []JobRecord{
+		{ID: "1", Tenant: "a", Spec: JobSpec{Inputs: []InputSpec{{Filename: "in.mkv", Start: 1 * Minute}}}, State: JobFailed, Error: "boom"},
+		{ID: "2", Priority: 3, State: JobQueued},
+	}
+
+	for _, record := range append(want, JobRecord{ID: "3"}) {
+		if err := store.Save(record); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if err := store.Delete("3"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if err := store.Delete("3"); err != nil {
+		t.Errorf("Unexpected error deleting missing record: %v", err)
+	}
+
+	got, err := store.Load()
+	sort.Slice(got, func(i, j int) bool { return got[i].ID < got[j].ID })
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+}
+
+func TestPersistentQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ffmpeg-store")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileJobStore(filepath.Join(dir, "jobs"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	partial := filepath.Join(dir, "partial.mkv")
+	if err := ioutil.WriteFile(partial, []byte("partial"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	store.Save(JobRecord{ID: "1", State: JobRunning, Spec: JobSpec{Outputs: []OutputSpec{{Filename: partial}}}})
+	store.Save(JobRecord{ID: "2", State: JobQueued})
+	store.Save(JobRecord{ID: "3", State: JobFailed, Error: "boom"})
+
+	queue, err := NewPersistentQueue(1, &TestTranscoder{}, store)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Errorf("expected partial output to be removed")
+	}
+
+	for _, id := range []string{"1", "2"} {
+		qj, found := queue.Job(id)
+		if !found {
+			t.Fatalf("expected job %s to be restored", id)
+		}
+
+		if err := qj.Wait(); err != nil || qj.State() != JobDone {
+			t.Errorf("job %s want done got %v %v", id, qj.State(), err)
+		}
+	}
+
+	if qj, found := queue.Job("3"); !found || qj.State() != JobFailed || qj.Err().Error() != "boom" {
+		t.Errorf("expected failed job to be restored")
+	}
+
+	qj, err := queue.Submit("", 0, JobSpec{})
+	if err != nil || qj.ID != "4" {
+		t.Errorf("want job 4 got %v %v", qj, err)
+	}
+	qj.Wait()
+	queue.Remove("3")
+	queue.Close()
+
+	records, _ := store.Load()
+	states := map[string]JobState{}
+	for _, record := range records {
+		states[record.ID] = record.State
+	}
+
+	if want := map[string]JobState{"1": JobDone, "2": JobDone, "4": JobDone}; !reflect.DeepEqual(want, states) {
+		t.Errorf("want %v got %v", want, states)
+	}
+}
+
+// failingStore is a JobStore that fails to save and delete once err is set
+type failingStore struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (fs *failingStore) fail(err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.err = err
+}
+
+func (fs *failingStore) Save(record JobRecord) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.err
+}
+
+func (fs *failingStore) Delete(id string) error     { return fs.Save(JobRecord{}) }
+func (fs *failingStore) Load() ([]JobRecord, error) { return nil, nil }
+
+func TestPersistentQueueStoreErrors(t *testing.T) {
+	store := &failingStore{}
+	queue, err := NewPersistentQueue(1, &TestTranscoder{}, store)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer queue.Close()
+
+	qj, err := queue.Submit("", 0, JobSpec{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	qj.Wait()
+
+	storeErr := errors.New("disk full")
+	store.fail(storeErr)
+	if _, err := queue.Submit("", 0, JobSpec{}); err != storeErr {
+		t.Errorf("want %v got %v", storeErr, err)
+	}
+
+	if queued, running := queue.Len(); queued != 0 || running != 0 || len(queue.Jobs()) != 1 {
+		t.Errorf("want only the first job got %d queued %d running %d jobs", queued, running, len(queue.Jobs()))
+	}
+
+	if err := queue.Remove(qj.ID); err != storeErr {
+		t.Errorf("want %v got %v", storeErr, err)
+	}
+
+	if _, found := queue.Job(qj.ID); !found {
+		t.Errorf("want job kept when it cannot be deleted")
+	}
+}
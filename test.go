@@ -1,5 +1,10 @@
 package ffmpeg
 
+import (
+	"strings"
+	"time"
+)
+
 // TestJob is a dummy transcode job returned by TestTranscoder.Transcode.  The
 // TestJob performs just like a normal transcode job, but will return error and log
 // based on those values set in the TestTranscoder
@@ -7,8 +12,16 @@ type TestJob struct {
 	// Canceled is set by the Cancel() method and is useful to test if a job
 	// was successfully canceled
 	Canceled bool
-	log      string
-	err      error
+
+	// Stopped is set by the Stop() method and is useful to test if a job
+	// was stopped gracefully
+	Stopped bool
+
+	// Paused is set by the Pause() method and cleared by the Resume() method
+	Paused bool
+	log    string
+	err    error
+	result TranscodeResult
 }
 
 func (tj *TestJob) Inspect() string {
@@ -20,6 +33,23 @@ func (tj *TestJob) Cancel() {
 	tj.Canceled = true
 }
 
+// Stop will set the Stopped property true
+func (tj *TestJob) Stop(grace time.Duration) {
+	tj.Stopped = true
+}
+
+// Pause will set the Paused property true
+func (tj *TestJob) Pause() error {
+	tj.Paused = true
+	return nil
+}
+
+// Resume will set the Paused property false
+func (tj *TestJob) Resume() error {
+	tj.Paused = false
+	return nil
+}
+
 // Err returns the JobErr set in the TestTranscoder
 func (tj *TestJob) Err() error {
 	return tj.err
@@ -30,6 +60,17 @@ func (tj *TestJob) Log() string {
 	return tj.log
 }
 
+// LogEntries returns an iterator over the lines of the Log value set in TestTranscoder
+func (tj *TestJob) LogEntries() *LogIterator {
+	log := newJobLog(DefaultLogSize)
+	if tj.log != "" {
+		for _, line := range strings.Split(tj.log, "\n") {
+			log.add(parseLogEntry(time.Time{}, line))
+		}
+	}
+	return log.iterator()
+}
+
 // Progress returns a channel that will be populated with exactly one
 // TranscodeInfo object and then immediately closed.
 func (tj *TestJob) Progress() <-chan TranscodeInfo {
@@ -39,6 +80,16 @@ func (tj *TestJob) Progress() <-chan TranscodeInfo {
 	return ch
 }
 
+// Subscribe returns a channel that works the same as the one returned by Progress
+func (tj *TestJob) Subscribe() (<-chan TranscodeInfo, func()) {
+	return tj.Progress(), func() {}
+}
+
+// Result returns the Result value set in TestTranscoder
+func (tj *TestJob) Result() TranscodeResult {
+	return tj.result
+}
+
 // Wait returns the JobErr set in the TestTranscoder
 func (tj *TestJob) Wait() error {
 	return tj.err
@@ -58,10 +109,13 @@ type TestTranscoder struct {
 
 	// Log is the string returned by TestJob.Log
 	Log string
+
+	// Result is the TranscodeResult returned by TestJob.Result
+	Result TranscodeResult
 }
 
 // Transcode will return a new TestJob with the log and err values set to the corresponding
 // TestTranscoder values, as well as any error set on TranscodeErr
 func (tt *TestTranscoder) Transcode(options ...TranscoderOption) (TranscodeJob, error) {
-	return &TestJob{log: tt.Log, err: tt.JobErr}, tt.TranscodeErr
+	return &TestJob{log: tt.Log, err: tt.JobErr, result: tt.Result}, tt.TranscodeErr
 }
@@ -3,6 +3,7 @@ package ffmpeg
 import (
 	"strconv"
 	"strings"
+	"time"
 )
 
 // TranscodeInfo contains information periodically emitted by a transcode job.  The TranscodeInfo
@@ -36,6 +37,21 @@ type TranscodeInfo struct {
 	// being processed twice as fast as it would be played then Speed is 2.0.  Likewise, if
 	// it is taking twice as long to process as to play, then it will be 0.5
 	Speed float64
+
+	// Percent is the percentage (0 to 100) of Duration that has been processed.  Percent
+	// is zero if Duration is not known
+	Percent float64
+
+	// ETA is the estimated wall clock time remaining, based on the average Speed
+	ETA time.Duration
+
+	// SmoothedETA is the estimated wall clock time remaining, based on an exponentially
+	// weighted moving average of the recent processing rate.  This reacts to changes in
+	// speed faster than ETA while not jumping around with every update
+	SmoothedETA time.Duration
+
+	// Paused is true while the transcode session is paused
+	Paused bool
 }
 
 func (ti *TranscodeInfo) update(values map[string]string) (err error) {
@@ -55,7 +71,9 @@ func (ti *TranscodeInfo) update(values map[string]string) (err error) {
 		case "total_size":
 			ti.TotalSize, err = strconv.ParseInt(v, 10, 64)
 		case "out_time_us":
-			continue
+			var us int64
+			us, err = strconv.ParseInt(v, 10, 64)
+			ti.Time = Time(us) * Microsecond
 		case "out_time_ms":
 			continue
 		case "out_time":
@@ -71,3 +89,83 @@ func (ti *TranscodeInfo) update(values map[string]string) (err error) {
 	}
 	return
 }
+
+// etaSmoothing is the weight given to the most recent processing rate when
+// computing TranscodeInfo.SmoothedETA
+const etaSmoothing = 0.2
+
+// etaEstimator computes the Percent and ETA values of successive TranscodeInfo updates.
+// Time spent paused is excluded from the processing rates
+type etaEstimator struct {
+	lastTime Time
+	lastWall time.Time
+	rate     float64
+
+	started  time.Time
+	pausedAt time.Time
+	paused   time.Duration
+}
+
+func (ee *etaEstimator) pause(now time.Time) {
+	if ee.pausedAt.IsZero() {
+		ee.pausedAt = now
+	}
+}
+
+func (ee *etaEstimator) resume(now time.Time) {
+	if ee.pausedAt.IsZero() {
+		return
+	}
+
+	paused := now.Sub(ee.pausedAt)
+	ee.paused += paused
+	if !ee.lastWall.IsZero() {
+		ee.lastWall = ee.lastWall.Add(paused)
+	}
+	ee.pausedAt = time.Time{}
+}
+
+func (ee *etaEstimator) update(ti *TranscodeInfo, now time.Time) {
+	position := ti.Time
+	if position < 0 {
+		position = 0
+	}
+
+	if ti.Duration <= 0 {
+		return
+	}
+
+	if position > ti.Duration {
+		position = ti.Duration
+	}
+	ti.Percent = 100 * float64(position) / float64(ti.Duration)
+	remaining := float64(ti.Duration - position)
+
+	// ffmpeg computes the speed from the wall clock time since it started,
+	// which includes the time spent paused
+	speed := ti.Speed
+	if elapsed := now.Sub(ee.started); ee.paused > 0 && elapsed > ee.paused {
+		speed *= float64(elapsed) / float64(elapsed-ee.paused)
+	}
+
+	ti.ETA = 0
+	if speed > 0 {
+		ti.ETA = time.Duration(remaining / speed)
+	}
+
+	if !ee.lastWall.IsZero() && now.After(ee.lastWall) && position >= ee.lastTime {
+		rate := float64(position-ee.lastTime) / float64(now.Sub(ee.lastWall))
+		if ee.rate == 0 {
+			ee.rate = rate
+		} else {
+			ee.rate = etaSmoothing*rate + (1-etaSmoothing)*ee.rate
+		}
+	}
+	ee.lastTime = position
+	ee.lastWall = now
+
+	ti.SmoothedETA = ti.ETA
+	if ee.rate > 0 {
+		ti.SmoothedETA = time.Duration(remaining / ee.rate)
+	}
+}
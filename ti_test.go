@@ -3,6 +3,7 @@ package ffmpeg
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestTranscodeInfoUpdate(t *testing.T) {
@@ -19,7 +20,8 @@ func TestTranscodeInfoUpdate(t *testing.T) {
 		{map[string]string{"bitrate": "three"}, TranscodeInfo{}, true},
 		{map[string]string{"total_size": "4"}, TranscodeInfo{TotalSize: 4}, false},
 		{map[string]string{"total_size": "four"}, TranscodeInfo{}, true},
-		{map[string]string{"out_time_us": "7"}, TranscodeInfo{}, false},
+		{map[string]string{"out_time_us": "7"}, TranscodeInfo{Time: 7 * Microsecond}, false},
+		{map[string]string{"out_time_us": "seven"}, TranscodeInfo{}, true},
 		{map[string]string{"out_time_ms": "6"}, TranscodeInfo{}, false},
 		{map[string]string{"out_time": "00:05:03.00000"}, TranscodeInfo{Time: Time(303000000000)}, false},
 		{map[string]string{"out_time": "five"}, TranscodeInfo{}, true},
@@ -48,3 +50,67 @@ func TestTranscodeInfoUpdate(t *testing.T) {
 		})
 	}
 }
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func closeTo(got, want time.Duration) bool {
+	diff := got - want
+	return -time.Millisecond < diff && diff < time.Millisecond
+}
+
+func TestETAEstimator(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		elapsed         time.Duration
+		info            TranscodeInfo
+		wantPercent     float64
+		wantETA         time.Duration
+		wantSmoothedETA time.Duration
+	}{
+		{0, TranscodeInfo{Duration: 100 * Second, Time: -1 * Second}, 0, 0, 0},
+		{10 * time.Second, TranscodeInfo{Duration: 100 * Second, Time: 20 * Second, Speed: 2}, 20, 40 * time.Second, 40 * time.Second},
+		{20 * time.Second, TranscodeInfo{Duration: 100 * Second, Time: 60 * Second, Speed: 3}, 60, seconds(40.0 / 3), seconds(40 / 2.4)},
+		{30 * time.Second, TranscodeInfo{Duration: 100 * Second, Time: 120 * Second, Speed: 4}, 100, 0, 0},
+		{40 * time.Second, TranscodeInfo{Time: 120 * Second, Speed: 4}, 0, 0, 0},
+	}
+
+	ee := &etaEstimator{}
+	for i, test := range tests {
+		info := test.info
+		ee.update(&info, start.Add(test.elapsed))
+		if info.Percent != test.wantPercent {
+			t.Errorf("tests[%d] Percent: want %v got %v", i, test.wantPercent, info.Percent)
+		}
+
+		if !closeTo(info.ETA, test.wantETA) {
+			t.Errorf("tests[%d] ETA: want %v got %v", i, test.wantETA, info.ETA)
+		}
+
+		if !closeTo(info.SmoothedETA, test.wantSmoothedETA) {
+			t.Errorf("tests[%d] SmoothedETA: want %v got %v", i, test.wantSmoothedETA, info.SmoothedETA)
+		}
+	}
+}
+
+func TestETAEstimatorPaused(t *testing.T) {
+	start := time.Now()
+	ee := &etaEstimator{started: start}
+
+	info := TranscodeInfo{Duration: 100 * Second, Time: 20 * Second, Speed: 2}
+	ee.update(&info, start.Add(10*time.Second))
+	ee.pause(start.Add(10 * time.Second))
+	ee.resume(start.Add(40 * time.Second))
+
+	// ffmpeg's speed includes the 30 seconds spent paused
+	info = TranscodeInfo{Duration: 100 * Second, Time: 40 * Second, Speed: 0.8}
+	ee.update(&info, start.Add(50*time.Second))
+	if !closeTo(info.ETA, 30*time.Second) {
+		t.Errorf("ETA: want %v got %v", 30*time.Second, info.ETA)
+	}
+
+	if !closeTo(info.SmoothedETA, 30*time.Second) {
+		t.Errorf("SmoothedETA: want %v got %v", 30*time.Second, info.SmoothedETA)
+	}
+}
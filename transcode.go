@@ -1,10 +1,13 @@
 package ffmpeg
 
 import (
-	"errors"
+	"context"
 	"io"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mh-orange/cmd"
 )
@@ -13,6 +16,10 @@ import (
 // with filters and produce an output.  Transcoder is useful when
 // changing a video's format, resolution, encoding etc.
 type Transcoder struct {
+	// Binaries selects the ffmpeg (and ffprobe, for inputs that need to be probed)
+	// executables, if nil then the package Ffmpeg and Ffprobe commands are used
+	Binaries *Binaries
+
 	options []TranscoderOption
 }
 
@@ -26,18 +33,22 @@ func NewTranscoder(options ...TranscoderOption) *Transcoder {
 	return transcoder
 }
 
-// Transcode will start a new transcoding process for the specific options and return a TranscodeJob
-// that can be monitored for completion.
-func (transcoder *Transcoder) Transcode(options ...TranscoderOption) (TranscodeJob, error) {
+// prepare creates a job for the process and applies the transcoder's options followed
+// by the given options.  The process is not started
+func (transcoder *Transcoder) prepare(ctx context.Context, proc cmd.Process, options []TranscoderOption) (*transcodeJob, error) {
 	var err error
-	options = append(transcoder.options, options...)
+	options = append(append([]TranscoderOption{}, transcoder.options...), options...)
 
 	job := &transcodeJob{
-		progressCh: make(chan TranscodeInfo, 1),
+		ctx:      ctx,
+		binaries: transcoder.Binaries,
+		log:      newJobLog(DefaultLogSize),
+		progress: newProgressBroadcaster(),
+		proc:     proc,
 	}
-	job.proc = Ffmpeg.Process()
+	job.progressCh, _ = job.progress.subscribe()
 
-	// search input for longest duration
+	// search input for longest duration (after start and duration options are applied)
 	for _, option := range options {
 		err = option.process(job)
 		if err != nil {
@@ -45,25 +56,50 @@ func (transcoder *Transcoder) Transcode(options ...TranscoderOption) (TranscodeJ
 		}
 
 		if input, ok := option.(*input); ok {
-			if input.Duration > job.info.Duration {
-				job.info.Duration = input.Duration
-			} else if input.fi != nil && input.fi.Format.Duration > job.info.Duration {
-				job.info.Duration = input.fi.Format.Duration
+			if duration := input.duration(); duration > job.info.Duration {
+				job.info.Duration = duration
 			}
 		}
 	}
+	return job, err
+}
+
+// Transcode will start a new transcoding process for the specific options and return a TranscodeJob
+// that can be monitored for completion.
+func (transcoder *Transcoder) Transcode(options ...TranscoderOption) (TranscodeJob, error) {
+	return transcoder.TranscodeContext(context.Background(), options...)
+}
+
+// TranscodeContext is like Transcode but the underlying ffmpeg process is killed if
+// the context is canceled or its deadline passes before the job completes.  When that
+// happens, TranscodeJob.Wait and TranscodeJob.Err return ctx.Err()
+func (transcoder *Transcoder) TranscodeContext(ctx context.Context, options ...TranscoderOption) (TranscodeJob, error) {
+	job, err := transcoder.prepare(ctx, transcoder.Binaries.ffmpeg().Process(), options)
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	if err == nil && job.checkCapabilities {
+		err = job.verify(ctx)
+	}
 
 	if err == nil {
 		stderr, writer := io.Pipe()
 		job.proc.Stderr(writer)
-		err = job.proc.Start()
+		err = notFound(job.proc.Start(), ErrFfmpegNotFound)
 		if err == nil {
+			job.started = time.Now()
+			job.estimator = &etaEstimator{started: job.started}
 			cancelCh := make(chan struct{})
 			job.cancelCh = cancelCh
 
 			doneCh := make(chan struct{})
 			job.doneCh = doneCh
+			if job.stallWindow > 0 {
+				job.watchdog = time.AfterFunc(job.stallWindow, job.stall)
+			}
 			go job.run(cancelCh, doneCh, stderr)
+			watchContext(ctx, job.proc, doneCh)
 		}
 	}
 	return job, err
@@ -72,38 +108,91 @@ func (transcoder *Transcoder) Transcode(options ...TranscoderOption) (TranscodeJ
 // TranscodeJob is a transcode session that is either ready to be started
 // or is currently running
 type TranscodeJob interface {
-	// Cancel attempts to stop/cancel a running transcode session
+	// Cancel attempts to stop/cancel a running transcode session.  The ffmpeg process
+	// is killed immediately, so the output is likely incomplete
 	Cancel()
 
-	// Err will return any error that occurred during the transcode session
+	// Pause suspends the ffmpeg process until Resume is called.  While paused the
+	// progress updates have Paused set and the ETA does not count the paused time
+	Pause() error
+
+	// Resume continues a paused transcode session
+	Resume() error
+
+	// Stop asks ffmpeg to quit cleanly so that it can finish writing the output (for
+	// instance an MP4 moov atom).  If ffmpeg has not exited after the grace period
+	// it is killed.  Wait returns ErrStopped when ffmpeg exited within the grace
+	// period, otherwise it returns a *TranscodeError caused by ErrKilled
+	Stop(grace time.Duration)
+
+	// Err will return any error that occurred during the transcode session.  If the
+	// ffmpeg process failed then the error is a *TranscodeError
 	Err() error
 
 	// Log is the string output from the underlying transcode command.  This is useful
 	// for determining if any errors occurred during transcoding
 	Log() string
 
+	// LogEntries returns an iterator over the parsed log lines.  Long logs are not
+	// kept in full, the first and most recent lines are kept (see LogSizeOption)
+	LogEntries() *LogIterator
+
 	// Progress returns a channel that receives TranscodeInfo objects as transcoding progresses.
-	// This is useful for displaying progress and feedback to users
+	// This is useful for displaying progress and feedback to users.  Progress always returns
+	// the same channel, use Subscribe when more than one consumer needs the updates
 	Progress() <-chan TranscodeInfo
 
+	// Subscribe returns a new channel that receives TranscodeInfo objects as transcoding
+	// progresses, along with a function that cancels the subscription.  Every subscriber
+	// gets its own channel.  A subscriber that falls behind misses intermediate updates,
+	// but the most recent update is always delivered and the final update is received
+	// before the channel is closed at the end of the transcode session
+	Subscribe() (<-chan TranscodeInfo, func())
+
 	// Wait will block until the underlying transcode process finishes.
 	Wait() error
 
 	// Inspect will return the full command line called (for ffmpeg)
 	Inspect() string
+
+	// Result returns the summary of what the transcode session produced.  The
+	// result is only complete after Wait returns
+	Result() TranscodeResult
 }
 
 type transcodeJob struct {
 	io.Reader
-	log []string
-	err error
+	log         *jobLog
+	logHandlers []func(LogEntry)
+	err         error
 
-	info TranscodeInfo
-	proc cmd.Process
+	ctx       context.Context
+	info      TranscodeInfo
+	result    TranscodeResult
+	anomalies anomalyDetector
+	started   time.Time
+	binaries  *Binaries
+	proc      cmd.Process
 
-	progressCh chan TranscodeInfo
+	requirements      []requirement
+	checkCapabilities bool
+
+	progress   *progressBroadcaster
+	progressCh <-chan TranscodeInfo
 	cancelCh   chan<- struct{}
 	doneCh     <-chan struct{}
+
+	// mu guards the progress and the stop state, which are changed by Pause,
+	// Resume, Stop and the watchdog while run is blocked reading stderr
+	mu          sync.Mutex
+	estimator   *etaEstimator
+	exited      bool
+	stopped     bool
+	forced      bool
+	stopTimer   *time.Timer
+	stallWindow time.Duration
+	watchdog    *time.Timer
+	stalled     bool
 }
 
 func (job *transcodeJob) Inspect() string {
@@ -123,37 +212,58 @@ func (job *transcodeJob) Inspect() string {
 
 func (job *transcodeJob) run(cancelCh chan struct{}, doneCh chan struct{}, stderr io.Reader) {
 	defer close(doneCh)
-	defer close(job.progressCh)
+	defer job.progress.close()
 
 	reader := newFilterReader(stderr, progPtrn, statsPtrn, finalStatsPtrn, repeatPtrn)
 
 	values := make(map[string]string)
 	running := true
+	killed := false
 
 	for running {
 		select {
 		case <-cancelCh:
 			job.proc.Kill()
 			running = false
+			killed = true
 		default:
 			if reader.Scan() {
 				if reader.Pattern() == nil {
-					job.log = append(job.log, reader.Text())
+					entry := parseLogEntry(time.Now(), reader.Text())
+					entry.Position = job.info.Time
+					job.log.add(entry)
+					for _, handler := range job.logHandlers {
+						handler(entry)
+					}
+					job.anomalies.line(reader.Text(), job.info.Time)
 				} else if reader.Pattern() == progPtrn {
 					tokens := strings.Split(reader.Text(), "=")
 					values[strings.TrimSpace(tokens[0])] = strings.TrimSpace(tokens[1])
 					if strings.TrimSpace(tokens[0]) == "progress" {
+						job.mu.Lock()
+						previous := job.info.Time
 						job.info.update(values)
-						select {
-						case job.progressCh <- job.info:
-						default:
+						if job.watchdog != nil && job.info.Time > previous {
+							job.watchdog.Reset(job.stallWindow)
+						}
+						job.estimator.update(&job.info, time.Now())
+						if values["progress"] == "end" {
+							job.info.Percent = 100
+							job.info.ETA = 0
+							job.info.SmoothedETA = 0
 						}
+						job.progress.publish(job.info)
+						job.mu.Unlock()
 						values = make(map[string]string)
 					}
+				} else if reader.Pattern() == finalStatsPtrn {
+					job.result.parse(reader.Text())
+				} else if reader.Pattern() == repeatPtrn {
+					job.anomalies.repeat(reader.Text())
 				}
 			} else {
 				if reader.Err() != nil && reader.Err() != io.EOF {
-					job.log = append(job.log, reader.Err().Error())
+					job.log.add(parseLogEntry(time.Now(), reader.Err().Error()))
 				}
 				job.proc.Kill()
 				running = false
@@ -162,34 +272,146 @@ func (job *transcodeJob) run(cancelCh chan struct{}, doneCh chan struct{}, stder
 	}
 
 	job.err = job.proc.Wait()
-	if job.err != nil {
-		if len(job.log) >= 2 {
-			job.err = errors.New(strings.TrimSpace(strings.Join(job.log[len(job.log)-2:], "\n")))
-		} else if len(job.log) == 1 {
-			job.err = errors.New(strings.TrimSpace(job.log[0]))
+	job.mu.Lock()
+	job.exited = true
+	stopped := job.stopped
+	stalled := job.stalled
+	if job.forced || stalled || (job.stopTimer != nil && !job.stopTimer.Stop()) {
+		killed = true
+	}
+
+	if job.watchdog != nil {
+		job.watchdog.Stop()
+	}
+	job.mu.Unlock()
+
+	job.result.Frames = job.info.Frame
+	job.result.Time = job.info.Time
+	job.result.Speed = job.info.Speed
+	job.result.Elapsed = time.Since(job.started)
+	job.result.Anomalies = job.anomalies.anomalies
+
+	if stopped && !killed {
+		job.err = ErrStopped
+	} else if job.err != nil && job.ctx.Err() != nil {
+		job.err = job.ctx.Err()
+	} else if job.err != nil || stalled {
+		te := newTranscodeError(job.err, job.Inspect(), job.log.lines(), killed)
+		if stalled {
+			te.Cause = ErrStalled
 		}
+		job.err = te
 	}
 }
 
+// stall is called by the watchdog when the output time has not advanced within
+// the stall window
+func (job *transcodeJob) stall() {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.exited || job.info.Paused {
+		return
+	}
+
+	job.stalled = true
+	job.proc.Kill()
+}
+
 func (job *transcodeJob) Err() error {
 	return job.err
 }
 
 func (job *transcodeJob) Log() string {
-	return strings.Join(job.log, "\n")
+	return job.log.String()
+}
+
+func (job *transcodeJob) LogEntries() *LogIterator {
+	return job.log.iterator()
+}
+
+func (job *transcodeJob) Result() TranscodeResult {
+	return job.result
 }
 
 func (job *transcodeJob) Progress() <-chan TranscodeInfo {
 	return job.progressCh
 }
 
+func (job *transcodeJob) Subscribe() (<-chan TranscodeInfo, func()) {
+	return job.progress.subscribe()
+}
+
 func (job *transcodeJob) Cancel() {
 	if job.cancelCh != nil {
-		job.cancelCh <- struct{}{}
+		select {
+		case job.cancelCh <- struct{}{}:
+		case <-job.doneCh:
+		}
 		job.cancelCh = nil
 	}
 }
 
+func (job *transcodeJob) Stop(grace time.Duration) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.doneCh == nil || job.exited || job.stopped {
+		return
+	}
+
+	// ffmpeg finishes writing the output when interrupted, run keeps reading
+	// stderr until it exits or the grace period runs out
+	job.stopped = true
+	if signalProcess(job.proc, os.Interrupt) == nil {
+		if job.info.Paused {
+			// a stopped process won't handle the interrupt until it continues
+			signalProcess(job.proc, sigCont)
+		}
+		job.stopTimer = time.AfterFunc(grace, func() { job.proc.Kill() })
+	} else {
+		job.forced = true
+		job.proc.Kill()
+	}
+}
+
+func (job *transcodeJob) Pause() error {
+	return job.pause(true, sigStop)
+}
+
+func (job *transcodeJob) Resume() error {
+	return job.pause(false, sigCont)
+}
+
+// pause signals the process to suspend or continue and publishes the new state
+func (job *transcodeJob) pause(paused bool, sig os.Signal) error {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.doneCh == nil || job.exited || job.stopped || job.info.Paused == paused {
+		return nil
+	}
+
+	if err := signalProcess(job.proc, sig); err != nil {
+		return err
+	}
+
+	job.info.Paused = paused
+	if paused {
+		job.estimator.pause(time.Now())
+	} else {
+		job.estimator.resume(time.Now())
+	}
+
+	// the watchdog doesn't count the time spent paused
+	if job.watchdog != nil {
+		if paused {
+			job.watchdog.Stop()
+		} else {
+			job.watchdog.Reset(job.stallWindow)
+		}
+	}
+	job.progress.publish(job.info)
+	return nil
+}
+
 func (job *transcodeJob) Wait() error {
 	<-job.doneCh
 	return job.err
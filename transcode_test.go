@@ -1,8 +1,14 @@
 package ffmpeg
 
 import (
+	"context"
+	"errors"
 	"io"
+	"os"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mh-orange/cmd"
 )
@@ -44,6 +50,230 @@ func TestTranscoderTranscode(t *testing.T) {
 	Ffmpeg = oldFfmpeg
 }
 
+func TestTranscoderTranscodeContext(t *testing.T) {
+	oldFfmpeg := Ffmpeg
+	Ffmpeg = &cmd.TestCmd{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := NewTranscoder().TranscodeContext(ctx)
+	if err != context.Canceled {
+		t.Errorf("wanted %v got %v", context.Canceled, err)
+	}
+
+	Ffmpeg = oldFfmpeg
+}
+
+func TestTranscoderBinaries(t *testing.T) {
+	oldFfmpeg := Ffmpeg
+	Ffmpeg = &cmd.TestCmd{StartErr: io.EOF}
+
+	transcoder := NewTranscoder()
+	transcoder.Binaries = &Binaries{Ffmpeg: &cmd.TestCmd{}}
+	job, err := transcoder.Transcode()
+	if err == nil {
+		err = job.Wait()
+	}
+
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	transcoder.Binaries = NewBinaries("/nonexistent/ffmpeg", "/nonexistent/ffprobe")
+	_, err = transcoder.Transcode()
+	if !errors.Is(err, ErrFfmpegNotFound) {
+		t.Errorf("wanted %v got %v", ErrFfmpegNotFound, err)
+	}
+
+	Ffmpeg = oldFfmpeg
+}
+
+// signalCmd creates processes that write a progress block and then block
+// until they are signaled or killed
+type signalCmd struct {
+	cmd.Command
+	ignore bool
+	proc   *signalingProcess
+}
+
+func (sc *signalCmd) Process() cmd.Process {
+	sc.proc = &signalingProcess{
+		Process: sc.Command.Process(),
+		ignore:  sc.ignore,
+		signals: make(chan os.Signal, 3),
+		killed:  make(chan struct{}),
+	}
+	return sc.proc
+}
+
+type signalingProcess struct {
+	cmd.Process
+	ignore   bool
+	stderr   io.WriteCloser
+	signals  chan os.Signal
+	received []os.Signal
+	killed   chan struct{}
+	kill     sync.Once
+	wg       sync.WaitGroup
+}
+
+func (sp *signalingProcess) Stderr(writer io.Writer) { sp.stderr = writer.(io.WriteCloser) }
+
+func (sp *signalingProcess) Start() error {
+	sp.wg.Add(1)
+	go func() {
+		defer sp.wg.Done()
+		defer sp.stderr.Close()
+		io.WriteString(sp.stderr, "frame=1\nprogress=continue\n")
+		// like a real process, an interrupt is not handled while stopped
+		stopped, interrupted := false, false
+		for {
+			select {
+			case sig := <-sp.signals:
+				sp.received = append(sp.received, sig)
+				switch sig {
+				case sigStop:
+					stopped = true
+				case sigCont:
+					stopped = false
+				case os.Interrupt:
+					interrupted = !sp.ignore
+				}
+
+				if interrupted && !stopped {
+					io.WriteString(sp.stderr, "Exiting normally, received signal 2.\n")
+					return
+				}
+			case <-sp.killed:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (sp *signalingProcess) Signal(sig os.Signal) error {
+	sp.signals <- sig
+	return nil
+}
+
+func (sp *signalingProcess) Kill() error {
+	sp.kill.Do(func() { close(sp.killed) })
+	return nil
+}
+
+func (sp *signalingProcess) Wait() error {
+	sp.wg.Wait()
+	return errors.New("exit status 255")
+}
+
+func TestTranscodeJobStop(t *testing.T) {
+	oldFfmpeg := Ffmpeg
+
+	tests := []struct {
+		name    string
+		ignore  bool
+		wantErr error
+	}{
+		{"exits", false, ErrStopped},
+		{"grace period expires", true, ErrKilled},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sc := &signalCmd{Command: &cmd.TestCmd{}, ignore: test.ignore}
+			Ffmpeg = sc
+			job, err := NewTranscoder().Transcode()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			<-job.Progress()
+			job.Stop(10 * time.Millisecond)
+			err = job.Wait()
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("wanted %v got %v", test.wantErr, err)
+			}
+
+			if want := []os.Signal{os.Interrupt}; !reflect.DeepEqual(want, sc.proc.received) {
+				t.Errorf("wanted %v got %v", want, sc.proc.received)
+			}
+
+			// stopping a finished job must not block
+			job.Stop(time.Second)
+		})
+	}
+
+	Ffmpeg = oldFfmpeg
+}
+
+func TestTranscodeJobPause(t *testing.T) {
+	if sigStop == nil {
+		t.Skip("pausing is not supported on this platform")
+	}
+
+	oldFfmpeg := Ffmpeg
+	sc := &signalCmd{Command: &cmd.TestCmd{}}
+	Ffmpeg = sc
+	job, err := NewTranscoder().Transcode()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updates, _ := job.Subscribe()
+	<-updates
+
+	for _, want := range []bool{true, false} {
+		if want {
+			err = job.Pause()
+		} else {
+			err = job.Resume()
+		}
+
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if info := <-updates; info.Paused != want {
+			t.Errorf("wanted paused %v got %v", want, info.Paused)
+		}
+	}
+
+	// pausing a paused job does nothing
+	job.Pause()
+	job.Pause()
+	<-updates
+	job.Stop(time.Second)
+	if err := job.Wait(); err != ErrStopped {
+		t.Errorf("wanted %v got %v", ErrStopped, err)
+	}
+
+	want := []os.Signal{sigStop, sigCont, sigStop, os.Interrupt, sigCont}
+	if !reflect.DeepEqual(want, sc.proc.received) {
+		t.Errorf("wanted %v got %v", want, sc.proc.received)
+	}
+
+	Ffmpeg = oldFfmpeg
+}
+
+func TestTranscodeJobWatchdog(t *testing.T) {
+	oldFfmpeg := Ffmpeg
+	Ffmpeg = &signalCmd{Command: &cmd.TestCmd{}}
+
+	job, err := NewTranscoder().Transcode(WatchdogOption(10 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = job.Wait()
+	var te *TranscodeError
+	if !errors.As(err, &te) || te.Cause != ErrStalled {
+		t.Errorf("wanted %v got %v", ErrStalled, err)
+	}
+
+	Ffmpeg = oldFfmpeg
+}
+
 func TestTranscoderRun(t *testing.T) {
 
 }
@@ -12,11 +12,15 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:generate enumer -type=AnomalyType -json=true -transform=comment
 //go:generate enumer -type=ColorRange -json=true -transform=comment
 //go:generate enumer -type=ColorSpace -json=true -transform=comment
 //go:generate enumer -type=FieldOrder -json=true -transform=comment
 //go:generate enumer -type=InterlaceType -json=true -transform=comment
+//go:generate enumer -type=JobState -json=true -transform=comment
+//go:generate enumer -type=LogLevel -json=true -transform=comment
 //go:generate enumer -type=MediaType -json=true -transform=comment
+//go:generate enumer -type=Verdict -json=true -transform=comment
 
 package ffmpeg
 
@@ -311,3 +315,93 @@ const (
 	// Attachment are attachment streams
 	Attachment // attachment
 )
+
+// JobState indicates where a queued transcode job is in its lifecycle
+type JobState int
+
+const (
+	// JobQueued indicates the job is waiting for a free worker
+	JobQueued JobState = iota // queued
+
+	// JobRunning indicates the ffmpeg process for the job is running
+	JobRunning // running
+
+	// JobDone indicates the job completed successfully
+	JobDone // done
+
+	// JobFailed indicates the job could not be started or ffmpeg failed
+	JobFailed // failed
+
+	// JobCanceled indicates the job was canceled before it completed
+	JobCanceled // canceled
+)
+
+// LogLevel is the severity of an ffmpeg log message, from least to most severe
+type LogLevel int
+
+const (
+	// LogTrace is extremely verbose debugging output
+	LogTrace LogLevel = iota // trace
+
+	// LogDebug is debugging output
+	LogDebug // debug
+
+	// LogVerbose is detailed informational output
+	LogVerbose // verbose
+
+	// LogInfo is standard informational output
+	LogInfo // info
+
+	// LogWarning indicates something unexpected that ffmpeg worked around
+	LogWarning // warning
+
+	// LogError indicates an error that ffmpeg may be able to recover from
+	LogError // error
+
+	// LogFatal indicates an error ffmpeg cannot recover from
+	LogFatal // fatal
+
+	// LogPanic indicates ffmpeg is about to crash
+	LogPanic // panic
+)
+
+// AnomalyType identifies a warning that ffmpeg logs when the input is damaged
+type AnomalyType int
+
+const (
+	// NonMonotonicDTS is logged when packet decoding timestamps go backwards
+	NonMonotonicDTS AnomalyType = iota // non-monotonic dts
+
+	// PastDurationTooLarge is logged when frame timestamps jump ahead of the
+	// frame rate, usually causing frames to be dropped
+	PastDurationTooLarge // past duration too large
+
+	// CorruptFrame is logged when a decoder produced a damaged frame
+	CorruptFrame // corrupt frame
+
+	// MissingReference is logged when a frame refers to a picture that was never
+	// decoded, usually because the input was cut or lost packets
+	MissingReference // missing reference
+
+	// TimestampDiscontinuity is logged when the input timestamps jump
+	TimestampDiscontinuity // timestamp discontinuity
+)
+
+// Verdict is the overall result of checking the integrity of an input, from
+// least to most severe
+type Verdict int
+
+const (
+	// VerdictOK indicates the input decoded without errors
+	VerdictOK Verdict = iota // ok
+
+	// VerdictDamaged indicates the input decoded, but with errors or corrupt frames
+	VerdictDamaged // damaged
+
+	// VerdictTruncated indicates less of the input could be decoded than its
+	// container says it holds
+	VerdictTruncated // truncated
+
+	// VerdictUnreadable indicates ffmpeg failed to decode the input
+	VerdictUnreadable // unreadable
+)
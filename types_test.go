@@ -32,6 +32,10 @@ func TestValues(t *testing.T) {
 		{func() interface{} { return FieldOrderValues() }, _FieldOrderValues},
 		{func() interface{} { return InterlaceTypeValues() }, _InterlaceTypeValues},
 		{func() interface{} { return MediaTypeValues() }, _MediaTypeValues},
+		{func() interface{} { return AnomalyTypeValues() }, _AnomalyTypeValues},
+		{func() interface{} { return JobStateValues() }, _JobStateValues},
+		{func() interface{} { return VerdictValues() }, _VerdictValues},
+		{func() interface{} { return LogLevelValues() }, _LogLevelValues},
 	}
 
 	for i, test := range tests {
@@ -88,6 +92,32 @@ func TestEnumer(t *testing.T) {
 		{Subtitle, "subtitle", true},
 		{Attachment, "attachment", true},
 		{MediaType(1024), "MediaType(1024)", false},
+		{NonMonotonicDTS, "non-monotonic dts", true},
+		{PastDurationTooLarge, "past duration too large", true},
+		{CorruptFrame, "corrupt frame", true},
+		{MissingReference, "missing reference", true},
+		{TimestampDiscontinuity, "timestamp discontinuity", true},
+		{AnomalyType(1024), "AnomalyType(1024)", false},
+		{JobQueued, "queued", true},
+		{JobRunning, "running", true},
+		{JobDone, "done", true},
+		{JobFailed, "failed", true},
+		{JobCanceled, "canceled", true},
+		{JobState(1024), "JobState(1024)", false},
+		{VerdictOK, "ok", true},
+		{VerdictDamaged, "damaged", true},
+		{VerdictTruncated, "truncated", true},
+		{VerdictUnreadable, "unreadable", true},
+		{Verdict(1024), "Verdict(1024)", false},
+		{LogTrace, "trace", true},
+		{LogDebug, "debug", true},
+		{LogVerbose, "verbose", true},
+		{LogInfo, "info", true},
+		{LogWarning, "warning", true},
+		{LogError, "error", true},
+		{LogFatal, "fatal", true},
+		{LogPanic, "panic", true},
+		{LogLevel(1024), "LogLevel(1024)", false},
 	}
 
 	for _, test := range tests {